@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+)
+
+// reloadableFlags are applied again on reload, all other flags in the config file are only read on startup
+var reloadableFlags = []string{
+	"log.verbose", "log.trace", "log.quiet",
+	"cache.size-cache-duration", "cache.disk-usage-cache-seconds", "cache.snapshot-duration",
+	"collector.system", "collector.container", "collector.container.net", "collector.container.net.per-interface",
+	"collector.container.cpu", "collector.container.fs", "collector.container.stats", "collector.container.memory",
+	"collector.container.blkio", "collector.container.health", "collector.container.restarts", "collector.container.labels",
+	"collector.compose", "collector.images", "collector.events", "compat.memory-kib",
+}
+
+// configFile sets flags from a yaml file with the flag names as keys, flags passed on the command line take precedence
+type configFile struct {
+	path  string
+	flags *pflag.FlagSet
+	// flags passed on the command line
+	cli map[string]bool
+
+	mu sync.Mutex
+	// applies the reloaded flags to the running exporter
+	apply func()
+}
+
+func newConfigFile(path string, flags *pflag.FlagSet) *configFile {
+	cli := make(map[string]bool)
+	flags.Visit(func(flag *pflag.Flag) {
+		cli[flag.Name] = true
+	})
+	return &configFile{path: path, flags: flags, cli: cli}
+}
+
+// load sets all flags from the file that were not passed on the command line
+func (c *configFile) load() error {
+	return c.set(func(string) bool { return true }, false)
+}
+
+// reload sets the reloadable flags from the file and applies them.
+//
+// Reloadable flags removed from the file are reset to their default, the flags are left unchanged if the file is invalid.
+func (c *configFile) reload() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" {
+		return errors.New("no --config.file to reload")
+	}
+	if err := c.set(func(name string) bool { return slices.Contains(reloadableFlags, name) }, true); err != nil {
+		return err
+	}
+	if c.apply != nil {
+		c.apply()
+	}
+	log.GetLogger().Info("Reloaded configuration", "config_file", c.path)
+	return nil
+}
+
+// set sets the flags matching filter from the file, reset resets matching flags missing in the file to their default
+func (c *configFile) set(filter func(name string) bool, reset bool) error {
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]any)
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", c.path, err)
+	}
+
+	for name := range values {
+		if c.flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q in %s", name, c.path)
+		}
+	}
+
+	// restore the previous values if the file contains an invalid value
+	previous := make(map[string][]string)
+	c.flags.VisitAll(func(flag *pflag.Flag) {
+		previous[flag.Name] = flagValues(flag)
+	})
+	var setErr error
+	c.flags.VisitAll(func(flag *pflag.Flag) {
+		if setErr != nil || c.cli[flag.Name] || !filter(flag.Name) {
+			return
+		}
+		value, ok := values[flag.Name]
+		if !ok {
+			if reset {
+				setErr = c.setFlag(flag, defaultValues(flag))
+			}
+			return
+		}
+		var list []string
+		switch v := value.(type) {
+		case []any:
+			for _, item := range v {
+				list = append(list, fmt.Sprint(item))
+			}
+		case map[any]any:
+			setErr = fmt.Errorf("invalid value of %q in %s (want a value or a list)", flag.Name, c.path)
+			return
+		default:
+			list = []string{fmt.Sprint(v)}
+		}
+		if err := c.setFlag(flag, list); err != nil {
+			setErr = fmt.Errorf("invalid value of %q in %s: %w", flag.Name, c.path, err)
+		}
+	})
+	if setErr != nil {
+		c.flags.VisitAll(func(flag *pflag.Flag) {
+			if !c.cli[flag.Name] && filter(flag.Name) {
+				_ = c.setFlag(flag, previous[flag.Name])
+			}
+		})
+		return setErr
+	}
+	return nil
+}
+
+func flagValues(flag *pflag.Flag) []string {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return slice.GetSlice()
+	}
+	return []string{flag.Value.String()}
+}
+
+func defaultValues(flag *pflag.Flag) []string {
+	if _, ok := flag.Value.(pflag.SliceValue); ok {
+		// slice defaults are formatted as [a,b]
+		values := strings.Trim(flag.DefValue, "[]")
+		if values == "" {
+			return nil
+		}
+		return strings.Split(values, ",")
+	}
+	return []string{flag.DefValue}
+}
+
+// setFlag sets the values of a flag, single value flags are marked as changed like on the command line
+func (c *configFile) setFlag(flag *pflag.Flag, values []string) error {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		// a single value may be a comma separated list, like on the command line
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		return slice.Replace(values)
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("want a single value, got %d", len(values))
+	}
+	return c.flags.Set(flag.Name, values[0])
+}
+
+// reloadOnSighup reloads the config file on every SIGHUP until ctx is cancelled
+func reloadOnSighup(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-hup:
+			log.GetLogger().Info("Received SIGHUP, reloading configuration")
+			if err := config.reload(); err != nil {
+				log.GetLogger().Error("Failed to reload configuration", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleReload reloads the config file on POST /-/reload
+func (c *configFile) handleReload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "405 Method Not Allowed (use POST)", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := c.reload(); err != nil {
+			log.GetLogger().ErrorContext(r.Context(), "Failed to reload configuration", "error", err)
+			http.Error(w, fmt.Sprintf("failed to reload configuration: %s", err), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("Configuration reloaded\n"))
+	}
+}
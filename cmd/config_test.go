@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type testFlags struct {
+	verbose  bool
+	duration time.Duration
+	labels   []string
+	states   []string
+}
+
+// newTestConfigFile parses args into a new flag set and returns a config file with content for it
+func newTestConfigFile(t *testing.T, content string, args ...string) (*configFile, *testFlags) {
+	t.Helper()
+	var values testFlags
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.BoolVar(&values.verbose, "log.verbose", false, "")
+	flags.DurationVar(&values.duration, "cache.size-cache-duration", time.Minute, "")
+	flags.StringSliceVar(&values.labels, "collector.container.labels", nil, "")
+	flags.StringSliceVar(&values.states, "filter.state", []string{"running", "exited"}, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return newConfigFile(path, flags), &values
+}
+
+func TestDefaultValues(t *testing.T) {
+	c, _ := newTestConfigFile(t, "")
+	tests := map[string][]string{
+		"log.verbose":                {"false"},
+		"cache.size-cache-duration":  {"1m0s"},
+		"collector.container.labels": nil,
+		"filter.state":               {"running", "exited"},
+	}
+	for name, want := range tests {
+		if got := defaultValues(c.flags.Lookup(name)); !slices.Equal(got, want) {
+			t.Errorf("defaultValues(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConfigFileLoad(t *testing.T) {
+	c, values := newTestConfigFile(t, `
+log.verbose: true
+cache.size-cache-duration: 10m
+collector.container.labels: [com.docker.compose.project, com.example.team]
+filter.state: created,dead
+`)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !values.verbose || values.duration != 10*time.Minute {
+		t.Errorf("verbose, duration = %v, %v, want true, 10m", values.verbose, values.duration)
+	}
+	if want := []string{"com.docker.compose.project", "com.example.team"}; !slices.Equal(values.labels, want) {
+		t.Errorf("labels from yaml list = %q, want %q", values.labels, want)
+	}
+	if want := []string{"created", "dead"}; !slices.Equal(values.states, want) {
+		t.Errorf("states from comma list = %q, want %q", values.states, want)
+	}
+}
+
+func TestConfigFileCommandLinePrecedence(t *testing.T) {
+	c, values := newTestConfigFile(t, `
+cache.size-cache-duration: 10m
+filter.state: [dead]
+`, "--cache.size-cache-duration=5m", "--filter.state=paused")
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if values.duration != 5*time.Minute {
+		t.Errorf("duration = %v, want command line value 5m", values.duration)
+	}
+	if want := []string{"paused"}; !slices.Equal(values.states, want) {
+		t.Errorf("states = %q, want command line value %q", values.states, want)
+	}
+}
+
+func TestConfigFileRollback(t *testing.T) {
+	c, values := newTestConfigFile(t, `
+log.verbose: true
+filter.state: [dead]
+cache.size-cache-duration: soon
+`)
+	if err := c.load(); err == nil {
+		t.Fatal("load() = nil error, want invalid duration error")
+	}
+	if values.verbose || values.duration != time.Minute {
+		t.Errorf("verbose, duration = %v, %v, want unchanged false, 1m", values.verbose, values.duration)
+	}
+	if want := []string{"running", "exited"}; !slices.Equal(values.states, want) {
+		t.Errorf("states = %q, want unchanged %q", values.states, want)
+	}
+}
+
+func TestConfigFileReloadResetsRemovedFlags(t *testing.T) {
+	c, values := newTestConfigFile(t, `
+log.verbose: true
+collector.container.labels: [team]
+`)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.path, []byte("cache.size-cache-duration: 2m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if values.verbose || values.duration != 2*time.Minute || len(values.labels) != 0 {
+		t.Errorf("verbose, duration, labels = %v, %v, %q, want false, 2m, []", values.verbose, values.duration, values.labels)
+	}
+}
+
+func TestConfigFileUnknownFlag(t *testing.T) {
+	c, _ := newTestConfigFile(t, "log.verbos: true\n")
+	if err := c.load(); err == nil {
+		t.Fatal("load() = nil error, want unknown flag error")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	toolkit "github.com/prometheus/exporter-toolkit/web"
+	"go.yaml.in/yaml/v2"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+)
+
+// listener serves one or more route groups (metrics, status, ui) on an address with its own web config
+type listener struct {
+	address    string
+	configFile string
+	groups     []string
+	mux        *http.ServeMux
+	server     *http.Server
+}
+
+type listeners []*listener
+
+// forGroup returns the mux to register the routes of group on.
+//
+// Groups with the same address share a listener, which fails if they use different web config files.
+// Addresses that only differ in how the wildcard host is written (":9100", "0.0.0.0:9100", "[::]:9100") are the same,
+// a wildcard and a specific host with the same port can't both be bound and fail.
+func (l *listeners) forGroup(group string, address string, configFile string) (*http.ServeMux, error) {
+	host, port, err := splitListenAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address of %s: %w", group, err)
+	}
+	for _, existing := range *l {
+		existingHost, existingPort, _ := splitListenAddress(existing.address)
+		if existingPort != port {
+			continue
+		}
+		if existingHost != host && (existingHost == "" || host == "") {
+			return nil, fmt.Errorf("%s on %s and %s on %s overlap on port %s", strings.Join(existing.groups, ", "), existing.address, group, address, port)
+		}
+		if existingHost == host {
+			if existing.configFile != configFile {
+				return nil, fmt.Errorf("%s and %s listen on %s with different web config files", strings.Join(existing.groups, ", "), group, address)
+			}
+			existing.groups = append(existing.groups, group)
+			return existing.mux, nil
+		}
+	}
+	mux := http.NewServeMux()
+	*l = append(*l, &listener{
+		address:    address,
+		configFile: configFile,
+		groups:     []string{group},
+		mux:        mux,
+		server: &http.Server{
+			Handler:  withRoutePrefix(routePrefix, mux),
+			ErrorLog: slog.NewLogLogger(log.GetLogger().Handler(), slog.LevelWarn),
+		},
+	})
+	return mux, nil
+}
+
+// splitListenAddress splits address into host and port, the wildcard hosts "", "0.0.0.0" and "::" are returned as ""
+func splitListenAddress(address string) (string, string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", err
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = ""
+	}
+	return host, port, nil
+}
+
+// serve starts all listeners in the background, the process exits if one of them fails
+func (l *listeners) serve() {
+	for _, li := range *l {
+		go func() {
+			log.GetLogger().Info("Listening on endpoint", "address", li.address, "groups", li.groups, "route_prefix", routePrefix, "web_config_file", li.configFile)
+			// the web config file is read again for every connection, so changes of users and certificates apply without a restart
+			if err := toolkit.ListenAndServe(li.server, &toolkit.FlagConfig{
+				WebListenAddresses: &[]string{li.address},
+				WebConfigFile:      &li.configFile,
+			}, log.GetLogger()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.GetLogger().Error("HTTP server failed", "error", err, "address", li.address)
+				os.Exit(1)
+			}
+		}()
+	}
+}
+
+// shutdown stops all listeners and waits for in-flight requests to finish,
+// connections still open when ctx ends are closed
+func (l *listeners) shutdown(ctx context.Context) error {
+	errs := make([]error, len(*l))
+	var wg sync.WaitGroup
+	for i, li := range *l {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := li.server.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", li.address, err)
+				_ = li.server.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// withRoutePrefix serves next under prefix, requests to the root are redirected to the prefix
+func withRoutePrefix(prefix string, next http.Handler) http.Handler {
+	if prefix == "" {
+		return next
+	}
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, next))
+	mux.Handle(prefix, http.RedirectHandler(prefix+"/", http.StatusFound))
+	mux.Handle("/{$}", http.RedirectHandler(prefix+"/", http.StatusFound))
+	return mux
+}
+
+// requireBearerToken only passes requests with an "Authorization: Bearer <token>" header to next
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.GetLogger().DebugContext(r.Context(), "Rejected request without valid bearer token", "path", r.URL.Path, "remote", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readWebConfig parses a Prometheus web config file, the zero config if path is empty
+func readWebConfig(path string) (toolkit.Config, error) {
+	var config toolkit.Config
+	if path == "" {
+		return config, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// authenticates returns if the web config only passes requests with basic auth or a verified client certificate
+func authenticates(config toolkit.Config) bool {
+	clientCA := config.TLSConfig.ClientCAs != "" || config.TLSConfig.ClientCAsText != ""
+	return len(config.Users) > 0 || (config.TLSConfig.ClientAuth == "RequireAndVerifyClientCert" && clientCA)
+}
+
+// readBearerToken reads the token from path, surrounding whitespace is removed
+func readBearerToken(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("bearer token file %s is empty", path)
+	}
+	return token, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthenticates(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    bool
+	}{
+		"empty":    {content: "", want: false},
+		"tls only": {content: "tls_server_config:\n  cert_file: server.crt\n  key_file: server.key\n", want: false},
+		"basic auth": {
+			content: "basic_auth_users:\n  admin: $2y$10$X0h1gDsPszWURQaxFN.dTu0Ge5OCdtgT4XIs/TBmwL.4p1NoKjbG6\n",
+			want:    true,
+		},
+		"client cert without ca": {
+			content: "tls_server_config:\n  client_auth_type: RequireAndVerifyClientCert\n",
+			want:    false,
+		},
+		"optional client cert": {
+			content: "tls_server_config:\n  client_auth_type: VerifyClientCertIfGiven\n  client_ca_file: ca.crt\n",
+			want:    false,
+		},
+		"verified client cert": {
+			content: "tls_server_config:\n  client_auth_type: RequireAndVerifyClientCert\n  client_ca_file: ca.crt\n",
+			want:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "web.yml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			config, err := readWebConfig(path)
+			if err != nil {
+				t.Fatalf("readWebConfig(): %v", err)
+			}
+			if got := authenticates(config); got != tt.want {
+				t.Errorf("authenticates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
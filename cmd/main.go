@@ -4,16 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
 	"github.com/prometheus/client_golang/prometheus"
+	toolkit "github.com/prometheus/exporter-toolkit/web"
 	"github.com/spf13/cobra"
 
 	"github.com/h3rmt/docker-exporter/internal/docker"
@@ -21,8 +24,6 @@ import (
 	"github.com/h3rmt/docker-exporter/internal/log"
 	"github.com/h3rmt/docker-exporter/internal/status"
 	"github.com/h3rmt/docker-exporter/internal/web"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -34,16 +35,59 @@ var (
 	homepage                  bool
 	sizeCacheDuration         time.Duration
 	diskUsageCacheDuration    time.Duration
+	invalidationDebounce      time.Duration
+	snapshotDuration          time.Duration
+	maxConcurrency            int
+	requestTimeout            time.Duration
+	timeoutOffset             time.Duration
+	shutdownTimeout           time.Duration
 	address                   string
 	port                      string
+	webConfigFile             string
+	statusAddress             string
+	statusConfigFile          string
+	uiAddress                 string
+	uiConfigFile              string
+	metricsBearerTokenFile    string
+	routePrefix               string
+	externalURL               string
 	dockerHost                string
 	collectorSystem           bool
 	collectorContainer        bool
 	collectorContainerNetwork bool
+	collectorContainerNetIf   bool
 	collectorContainerCPU     bool
 	collectorContainerFS      bool
 	collectorContainerStats   bool
+	collectorContainerHealth  bool
+	collectorContainerMemory  bool
+	collectorContainerBlkio   bool
+	compatMemoryKiB           bool
+	collectorContainerRestart bool
+	crashLoopRestarts         int
+	crashLoopWindow           time.Duration
+	statsBackend              string
+	gcInterval                time.Duration
+	cgroupRoot                string
+	procRoot                  string
 	collectorImages           bool
+	collectorEvents           bool
+	collectorContainerLabels  []string
+	collectorCompose          bool
+	filterNameInclude         string
+	filterNameExclude         string
+	filterLabelInclude        []string
+	filterLabelExclude        []string
+	filterImageInclude        string
+	filterImageExclude        string
+	filterStates              []string
+	configFilePath            string
+	enableReload              bool
+
+	// path of externalURL (default routePrefix), prepended to the links of the homepage
+	externalPath string
+	// flags read from configFilePath, reloaded on SIGHUP
+	config *configFile
 )
 
 var rootCmd = &cobra.Command{
@@ -51,36 +95,131 @@ var rootCmd = &cobra.Command{
 	Short: "Docker Prometheus exporter",
 	Args:  cobra.NoArgs,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		config = newConfigFile(configFilePath, cmd.Flags())
+		if configFilePath != "" {
+			if err := config.load(); err != nil {
+				return fmt.Errorf("invalid --config.file: %w", err)
+			}
+		}
 		// Enum check
 		switch logFormat {
 		case "json", "logfmt":
 		default:
 			return fmt.Errorf("invalid --log-format: %s (want json|logfmt)", logFormat)
 		}
+		switch statsBackend {
+		case docker.StatsBackendAPI, docker.StatsBackendCgroupfs, docker.StatsBackendStream:
+		default:
+			return fmt.Errorf("invalid --stats.backend: %s (want %s|%s|%s)", statsBackend, docker.StatsBackendAPI, docker.StatsBackendCgroupfs, docker.StatsBackendStream)
+		}
+		if externalURL != "" {
+			u, err := url.Parse(externalURL)
+			if err != nil {
+				return fmt.Errorf("invalid --web.external-url: %w", err)
+			}
+			externalPath = normalizePrefix(u.Path)
+		}
+		if cmd.Flags().Changed("web.route-prefix") {
+			routePrefix = normalizePrefix(routePrefix)
+		} else {
+			// like prometheus, routes are served under the path of the external url by default
+			routePrefix = externalPath
+		}
+		if externalURL == "" {
+			// without a proxy rewriting the path, the homepage links to the routes under the prefix
+			externalPath = routePrefix
+		}
+		for flag, file := range map[string]string{
+			"--web.config.file":        webConfigFile,
+			"--web.status.config.file": statusConfigFile,
+			"--web.ui.config.file":     uiConfigFile,
+		} {
+			if err := toolkit.Validate(file); err != nil {
+				return fmt.Errorf("invalid %s: %w", flag, err)
+			}
+		}
+		if metricsBearerTokenFile != "" {
+			metricsWebConfig, err := readWebConfig(webConfigFile)
+			if err != nil {
+				return fmt.Errorf("invalid --web.config.file: %w", err)
+			}
+			if len(metricsWebConfig.Users) > 0 {
+				// the web config only passes requests with basic auth, which can't carry the bearer token as well
+				return errors.New("--web.metrics.bearer-token-file can't be combined with basic_auth_users in --web.config.file")
+			}
+		}
+		if enableReload {
+			// /-/reload is served with /status, which anyone reaching the port could call otherwise
+			statusWebConfig, err := readWebConfig(valueOr(statusConfigFile, webConfigFile))
+			if err != nil {
+				return fmt.Errorf("invalid web config file of the status address: %w", err)
+			}
+			if !authenticates(statusWebConfig) {
+				return errors.New("--web.enable-reload requires basic_auth_users or verified client certificates in --web.status.config.file or --web.config.file")
+			}
+		}
 		return nil
 	},
 	Run: run,
 }
 
 func init() {
+	rootCmd.Flags().StringVar(&configFilePath, "config.file", "", "Path to a yaml file with flag names as keys, reloaded on SIGHUP (command line flags take precedence).")
+	rootCmd.Flags().BoolVar(&enableReload, "web.enable-reload", false, "Enable POST /-/reload to reload --config.file (served with /status, requires authentication for it).")
 	rootCmd.Flags().StringVarP(&dockerHost, "docker-host", "d", "unix:///var/run/docker.sock", "Host to connect to.")
 	rootCmd.Flags().BoolVarP(&verbose, "log.verbose", "v", false, "Verbose mode (enabled debug logs).")
 	rootCmd.Flags().BoolVar(&trace, "log.trace", false, "Very Verbose mode (enabled trace logs).")
 	rootCmd.Flags().BoolVarP(&quiet, "log.quiet", "q", false, "Quiet mode (disables info logs).")
 	rootCmd.Flags().StringVar(&logFormat, "log.format", "logfmt", "Log format: 'logfmt' or 'json'.")
+	rootCmd.Flags().IntVar(&maxConcurrency, "docker.max-concurrency", 10, "Maximum number of concurrent docker requests per scrape (0 for unlimited).")
+	rootCmd.Flags().DurationVar(&requestTimeout, "docker.request-timeout", time.Duration(10)*time.Second, "Timeout of a single docker request (0 to disable).")
 	rootCmd.Flags().DurationVar(&sizeCacheDuration, "cache.size-cache-duration", time.Duration(300)*time.Second, "Duration to wait before refreshing container size cache.")
 	rootCmd.Flags().DurationVar(&diskUsageCacheDuration, "cache.disk-usage-cache-seconds", time.Duration(120)*time.Second, "Duration to wait before refreshing docker disk usage cache.")
+	rootCmd.Flags().DurationVar(&snapshotDuration, "cache.snapshot-duration", time.Duration(5)*time.Second, "Duration to reuse a container snapshot for other scrapes and the homepage (0 to only share concurrent scrapes).")
+	rootCmd.Flags().DurationVar(&invalidationDebounce, "cache.invalidation-debounce", time.Duration(10)*time.Second, "Duration to wait after a docker event before refreshing the affected caches.")
+	rootCmd.Flags().IntVar(&crashLoopRestarts, "crashloop.restarts", 3, "Number of restarts in --crashloop.window after which a container is crash-looping (0 to disable).")
+	rootCmd.Flags().DurationVar(&crashLoopWindow, "crashloop.window", time.Duration(5)*time.Minute, "Window to count restarts in for crash-loop detection.")
+	rootCmd.Flags().StringVar(&statsBackend, "stats.backend", docker.StatsBackendAPI, "Backend to read container stats from: 'api', 'cgroupfs' or 'stream'.")
+	rootCmd.Flags().StringVar(&cgroupRoot, "stats.cgroup-root", "/sys/fs/cgroup", "Mount point of the host cgroup hierarchy (cgroupfs backend).")
+	rootCmd.Flags().StringVar(&procRoot, "stats.proc-root", "/proc", "Mount point of the host procfs (cgroupfs backend).")
+	rootCmd.Flags().StringVar(&filterNameInclude, "filter.name-include", "", "Regex of container names to include (all if empty).")
+	rootCmd.Flags().StringVar(&filterNameExclude, "filter.name-exclude", "", "Regex of container names to exclude.")
+	rootCmd.Flags().StringSliceVar(&filterLabelInclude, "filter.label-include", []string{}, "Comma separated list of label selectors (key or key=value) containers must match.")
+	rootCmd.Flags().StringSliceVar(&filterLabelExclude, "filter.label-exclude", []string{}, "Comma separated list of label selectors (key or key=value) of containers to exclude.")
+	rootCmd.Flags().StringVar(&filterImageInclude, "filter.image-include", "", "Regex of container images to include (all if empty).")
+	rootCmd.Flags().StringVar(&filterImageExclude, "filter.image-exclude", "", "Regex of container images to exclude.")
+	rootCmd.Flags().StringSliceVar(&filterStates, "filter.state", []string{}, "Comma separated list of container states to include (all if empty).")
+	rootCmd.Flags().DurationVar(&gcInterval, "gc.interval", time.Duration(10)*time.Minute, "Interval to remove state of deleted containers (0 to disable, destroy events are still handled).")
+	rootCmd.Flags().DurationVar(&timeoutOffset, "web.timeout-offset", time.Duration(500)*time.Millisecond, "Offset to subtract from the Prometheus scrape timeout.")
+	rootCmd.Flags().DurationVar(&shutdownTimeout, "web.shutdown-timeout", time.Duration(30)*time.Second, "Time to wait for in-flight requests to finish on shutdown.")
 	rootCmd.Flags().BoolVar(&homepage, "web.homepage", true, "Show homepage with charts.")
 	rootCmd.Flags().StringVarP(&address, "web.address", "a", "0.0.0.0", "Address to listen on.")
 	rootCmd.Flags().StringVarP(&port, "web.port", "p", "9100", "Port to listen on.")
+	rootCmd.Flags().StringVar(&webConfigFile, "web.config.file", "", "Path to a Prometheus web configuration file to enable TLS and basic auth.")
+	rootCmd.Flags().StringVar(&externalURL, "web.external-url", "", "URL under which the exporter is reachable, e.g. behind a reverse proxy (its path is used in the links of the homepage).")
+	rootCmd.Flags().StringVar(&routePrefix, "web.route-prefix", "", "Prefix of all routes (default the path of --web.external-url).")
+	rootCmd.Flags().StringVar(&metricsBearerTokenFile, "web.metrics.bearer-token-file", "", "Path to a file with a bearer token required to scrape /metrics.")
+	rootCmd.Flags().StringVar(&statusAddress, "web.status.listen-address", "", "Address (host:port) to serve /status on (default the metrics address).")
+	rootCmd.Flags().StringVar(&statusConfigFile, "web.status.config.file", "", "Web configuration file of the status address (default --web.config.file).")
+	rootCmd.Flags().StringVar(&uiAddress, "web.ui.listen-address", "", "Address (host:port) to serve the homepage and /api on (default the metrics address).")
+	rootCmd.Flags().StringVar(&uiConfigFile, "web.ui.config.file", "", "Web configuration file of the homepage address (default --web.config.file).")
 	rootCmd.Flags().BoolVar(&internalMetrics, "collector.internal-metrics", false, "Enable internal go metrics.")
 	rootCmd.Flags().BoolVar(&collectorSystem, "collector.system", true, "Enable system collector (exporter info, host OS info).")
 	rootCmd.Flags().BoolVar(&collectorContainer, "collector.container", true, "Enable container collector.")
 	rootCmd.Flags().BoolVar(&collectorContainerNetwork, "collector.container.net", true, "Enable container network collector.")
+	rootCmd.Flags().BoolVar(&collectorContainerNetIf, "collector.container.net.per-interface", false, "Export container network metrics per interface instead of the container total.")
 	rootCmd.Flags().BoolVar(&collectorContainerCPU, "collector.container.cpu", true, "Enable container cpu usage collector.")
 	rootCmd.Flags().BoolVar(&collectorContainerFS, "collector.container.fs", true, "Enable container fs collector.")
 	rootCmd.Flags().BoolVar(&collectorContainerStats, "collector.container.stats", true, "Enable container stats collector.")
+	rootCmd.Flags().BoolVar(&collectorContainerMemory, "collector.container.memory", true, "Enable container memory breakdown collector.")
+	rootCmd.Flags().BoolVar(&collectorContainerBlkio, "collector.container.blkio", true, "Enable container block io per device collector.")
+	rootCmd.Flags().BoolVar(&compatMemoryKiB, "compat.memory-kib", false, "Also export the deprecated docker_container_mem_*_kib metrics.")
+	rootCmd.Flags().BoolVar(&collectorContainerHealth, "collector.container.health", true, "Enable container health check collector.")
+	rootCmd.Flags().BoolVar(&collectorContainerRestart, "collector.container.restarts", true, "Enable container restarts and crash-loop collector.")
+	rootCmd.Flags().StringSliceVar(&collectorContainerLabels, "collector.container.labels", []string{}, "Comma separated list of container labels to add to docker_container_info.")
+	rootCmd.Flags().BoolVar(&collectorCompose, "collector.compose", true, "Enable docker compose project collector.")
 	rootCmd.Flags().BoolVar(&collectorImages, "collector.images", true, "Enable images collector.")
+	rootCmd.Flags().BoolVar(&collectorEvents, "collector.events", true, "Enable docker events collector.")
 }
 
 var (
@@ -109,78 +248,121 @@ func run(*cobra.Command, []string) {
 		log.GetLogger().Info("IP environment variable not set, pass it do display the IP of the exporter on the homepage", "missing_env", "IP")
 	}
 
-	dockerClient, err := docker.NewDockerClient(dockerHost, sizeCacheDuration, diskUsageCacheDuration)
+	// cancelled once the HTTP servers are drained, stops all background work
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// background goroutines started here, waited for on shutdown
+	var wg sync.WaitGroup
+
+	dockerClient, err := docker.NewDockerClient(ctx, docker.Config{
+		Host:                   dockerHost,
+		SizeCacheDuration:      sizeCacheDuration,
+		DiskUsageCacheDuration: diskUsageCacheDuration,
+		InvalidationDebounce:   invalidationDebounce,
+		SnapshotMaxAge:         snapshotDuration,
+		MaxConcurrency:         maxConcurrency,
+		RequestTimeout:         requestTimeout,
+		CrashLoopRestarts:      crashLoopRestarts,
+		CrashLoopWindow:        crashLoopWindow,
+		StatsBackend:           statsBackend,
+		CgroupRoot:             cgroupRoot,
+		ProcRoot:               procRoot,
+		Filter: docker.FilterConfig{
+			NameInclude:  filterNameInclude,
+			NameExclude:  filterNameExclude,
+			LabelInclude: filterLabelInclude,
+			LabelExclude: filterLabelExclude,
+			ImageInclude: filterImageInclude,
+			ImageExclude: filterImageExclude,
+			States:       filterStates,
+		},
+	})
 	if err != nil {
 		log.GetLogger().Error("Failed to create Docker client", "error", err, "docker_host", dockerHost)
 		os.Exit(1)
 	}
 
 	log.GetLogger().Info("Initializing Docker Prometheus exporter...")
-	collectorConfig := exporter.CollectorConfig{
-		System:           collectorSystem,
-		Container:        collectorContainer || collectorContainerNetwork || collectorContainerFS || collectorContainerStats || collectorContainerCPU,
-		ContainerNetwork: collectorContainerNetwork,
-		ContainerCPU:     collectorContainerCPU,
-		ContainerFS:      collectorContainerFS,
-		ContainerStats:   collectorContainerStats,
-		Images:           collectorImages,
-	}
-	var reg prometheus.Gatherer
+	collectorConfig := collectorConfigFromFlags()
+	handlerConfig := exporter.HandlerConfig{
+		TimeoutOffset: timeoutOffset,
+		// only read on startup, reloading log.trace changes the log level but not the exposition format
+		EnableOpenMetrics: trace,
+	}
 	if internalMetrics {
-		reg = prometheus.DefaultGatherer
-		exporter.RegisterCollectorsWithRegistry(dockerClient, nil, Version, collectorConfig)
-	} else {
-		// Create a custom registry that doesn't include the Go collector, process collector, etc.
-		registry := prometheus.NewRegistry()
-		exporter.RegisterCollectorsWithRegistry(dockerClient, registry, Version, collectorConfig)
-		reg = registry
+		// the default registry includes the Go collector, process collector, etc.
+		handlerConfig.Internal = prometheus.DefaultGatherer
+	}
+	metricsHandler := exporter.NewHandler(dockerClient, Version, collectorConfig, handlerConfig)
+	// the docker client and its state (cpu usage, restarts, caches) are kept on reload
+	config.apply = func() {
+		log.SetLevel(verbose, trace, quiet)
+		metricsHandler.SetCollectorConfig(collectorConfigFromFlags())
+		dockerClient.SetCacheDurations(sizeCacheDuration, diskUsageCacheDuration, snapshotDuration)
 	}
 
-	registerHttp(dockerClient, reg)
+	servers, err := registerHttp(dockerClient, metricsHandler)
+	if err != nil {
+		log.GetLogger().Error("Failed to set up HTTP endpoints", "error", err)
+		os.Exit(1)
+	}
 
-	server := &http.Server{Addr: fmt.Sprintf("%s:%s", address, port), ErrorLog: slog.NewLogLogger(log.GetLogger().Handler(), slog.LevelWarn)}
-	log.GetLogger().Info("HTTP server created")
+	// Events are always watched as they are also used to invalidate caches
+	wg.Add(1)
 	go func() {
-		log.GetLogger().Info("Listening on metrics endpoint", "address", fmt.Sprintf("%s:%s", address, port))
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.GetLogger().Error("HTTP server failed", "error", err)
-			os.Exit(1)
-		}
+		defer wg.Done()
+		dockerClient.WatchEvents(ctx)
+		log.GetLogger().Debug("Docker events watcher stopped")
 	}()
+	if gcInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dockerClient.RunGC(ctx, gcInterval)
+			log.GetLogger().Debug("Container garbage collector stopped")
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reloadOnSighup(ctx)
+	}()
+	if homepage {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			web.CollectInBg(ctx)
+			log.GetLogger().Debug("Metrics in background collector stopped")
+		}()
+	}
+
+	servers.serve()
 
 	// Collect initial metrics in background
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		log.GetLogger().Info("Collecting initial metrics in background...")
-		ctx := context.Background()
 		start := time.Now()
 
 		// load disk usage cache
 		dockerClient.Disk(ctx)
 
 		// Perform an initial collection to warm up caches
-		containers, err := dockerClient.ListAllRunningContainers(ctx)
+		// stats always include cpu because webapi shows cpu usage even if collector is disabled
+		snapshot, err := dockerClient.ContainerSnapshot(ctx, docker.SnapshotOptions{
+			Size:  collectorConfig.ContainerFS,
+			Stats: true,
+			Cpu:   true,
+		})
+		if ctx.Err() != nil {
+			log.GetLogger().Debug("Initial metrics collection cancelled")
+			return
+		}
 		if err != nil {
 			log.GetLogger().Warn("Initial container listing failed", "error", err)
-		} else if len(containers) > 0 {
-			// Warm up by inspecting and getting stats for all containers concurrently
-			log.GetLogger().Debug("Warming up container stats cache", "count", len(containers))
-
-			var wg sync.WaitGroup
-			// Use a semaphore to limit concurrent requests
-			sem := make(chan struct{}, 5)
-
-			for _, container := range containers {
-				wg.Add(1)
-				go func(c docker.ContainerInfo) {
-					defer wg.Done()
-					sem <- struct{}{}        // acquire
-					defer func() { <-sem }() // release
-
-					_, _ = dockerClient.InspectContainer(ctx, c.ID, collectorConfig.ContainerFS)
-					_, _ = dockerClient.GetContainerStats(ctx, c.ID, true) // always true because webapi shows cpu usage even if collector is disabled
-				}(container)
-			}
-			wg.Wait()
+		} else {
+			log.GetLogger().Debug("Warmed up container stats cache", "count", len(snapshot.Containers))
 		}
 
 		// test slow startup
@@ -192,27 +374,75 @@ func run(*cobra.Command, []string) {
 	}()
 
 	// Graceful shutdown
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-signalCtx.Done()
+	// a second signal kills the process
+	stop()
 
-	web.StopCollect()
-	log.GetLogger().Info("Shutting down exporter...")
-	err = server.Close()
-	if err != nil {
-		log.GetLogger().Error("Failed to close HTTP server", "error", err)
-		os.Exit(1)
+	log.GetLogger().Info("Shutting down exporter...", "drain_timeout", shutdownTimeout)
+	// scrapes in flight still need the docker client, so it is stopped after the servers are drained
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := servers.shutdown(shutdownCtx); err != nil {
+		log.GetLogger().Warn("HTTP servers not drained in time, closing open connections", "error", err)
+	}
+
+	cancel()
+	wg.Wait()
+	if err := dockerClient.Close(); err != nil {
+		log.GetLogger().Warn("Failed to close Docker client", "error", err)
 	}
+	log.GetLogger().Info("Exporter stopped")
 }
 
-func registerHttp(dockerClient *docker.Client, reg prometheus.Gatherer) {
-	http.HandleFunc("/status", status.HandleStatus(dockerClient, Version))
+// collectorConfigFromFlags returns the collector config set by the --collector.* flags
+func collectorConfigFromFlags() exporter.CollectorConfig {
+	return exporter.CollectorConfig{
+		System:                       collectorSystem,
+		Disk:                         collectorSystem,
+		Container:                    collectorContainer || collectorContainerNetwork || collectorContainerFS || collectorContainerStats || collectorContainerCPU || collectorContainerMemory || collectorContainerBlkio || collectorContainerHealth || collectorContainerRestart || collectorCompose,
+		ContainerNetwork:             collectorContainerNetwork,
+		ContainerNetworkPerInterface: collectorContainerNetIf,
+		ContainerCPU:                 collectorContainerCPU,
+		ContainerFS:                  collectorContainerFS,
+		ContainerStats:               collectorContainerStats,
+		ContainerMemory:              collectorContainerMemory,
+		ContainerBlkio:               collectorContainerBlkio,
+		MemoryKiB:                    compatMemoryKiB,
+		ContainerHealth:              collectorContainerHealth,
+		ContainerRestart:             collectorContainerRestart,
+		Images:                       collectorImages,
+		Events:                       collectorEvents,
+		Compose:                      collectorCompose,
+		ContainerLabels:              collectorContainerLabels,
+	}
+}
+
+// registerHttp registers the metrics, status and ui route groups on their listeners
+func registerHttp(dockerClient *docker.Client, metricsHandler http.Handler) (listeners, error) {
+	var servers listeners
+	mainAddress := net.JoinHostPort(address, port)
+	metricsMux, err := servers.forGroup("metrics", mainAddress, webConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	statusMux, err := servers.forGroup("status", valueOr(statusAddress, mainAddress), valueOr(statusConfigFile, webConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	uiMux, err := servers.forGroup("ui", valueOr(uiAddress, mainAddress), valueOr(uiConfigFile, webConfigFile))
+	if err != nil {
+		return nil, err
+	}
+
+	statusHandler := status.HandleStatus(dockerClient, Version)
+	statusMux.HandleFunc("/status", statusHandler)
+	if enableReload {
+		statusMux.HandleFunc("/-/reload", config.handleReload())
+	}
 
 	// Wrapper for /metrics that returns 503 when not ready
-	metricsHandler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{
-		EnableOpenMetrics: trace,
-	})
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+	var metrics http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !glob.IsReady() {
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 			w.WriteHeader(http.StatusServiceUnavailable)
@@ -221,25 +451,51 @@ func registerHttp(dockerClient *docker.Client, reg prometheus.Gatherer) {
 		}
 		metricsHandler.ServeHTTP(w, r)
 	})
+	if metricsBearerTokenFile != "" {
+		token, err := readBearerToken(metricsBearerTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		metrics = requireBearerToken(token, metrics)
+	}
+	metricsMux.Handle("/metrics", metrics)
 
 	// Web UI and API
 	if homepage {
-		http.HandleFunc("/", web.HandleRoot())
-		http.HandleFunc("/{path}", web.HandleAsset())
-
-		http.HandleFunc("/api/info", web.HandleAPIInfo(Version))
-		http.HandleFunc("/api/usage", web.HandleAPIUsage())
-		http.HandleFunc("/api/containers", web.HandleAPIContainers(dockerClient))
-		http.HandleFunc("/api/images", web.HandleApiImages(dockerClient))
+		uiMux.HandleFunc("/", web.HandleRoot(externalPath))
+		uiMux.HandleFunc("/{path}", web.HandleAsset())
+		// the homepage shows the status
+		if uiMux != statusMux {
+			uiMux.HandleFunc("/status", statusHandler)
+		}
 
-		go func() {
-			web.CollectInBg()
-			log.GetLogger().Debug("Metrics in background collector stopped")
-		}()
+		uiMux.HandleFunc("/api/info", web.HandleAPIInfo(Version))
+		uiMux.HandleFunc("/api/usage", web.HandleAPIUsage())
+		uiMux.HandleFunc("/api/containers", web.HandleAPIContainers(dockerClient))
+		uiMux.HandleFunc("/api/projects", web.HandleAPIProjects(dockerClient))
+		uiMux.HandleFunc("/api/images", web.HandleApiImages(dockerClient))
 	} else {
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		uiMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNotFound)
 			_, _ = w.Write([]byte("404 page not found (homepage disabled)\n"))
 		})
 	}
+	return servers, nil
+}
+
+// normalizePrefix turns a url path into a route prefix with a leading and without a trailing slash, "" for the root
+func normalizePrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// valueOr returns value, or fallback if value is empty
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
 }
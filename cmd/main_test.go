@@ -0,0 +1,50 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestHomepageUnderRoutePrefix(t *testing.T) {
+	if err := rootCmd.ParseFlags([]string{"--web.route-prefix=/docker-exporter"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rootCmd.PreRunE(rootCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	servers, err := registerHttp(nil, http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := servers[0].server.Handler
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/docker-exporter/")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /docker-exporter/ = %d, want %d", rec.Code, http.StatusOK)
+	}
+	page := rec.Body.String()
+	// main.js prepends the base path to the api urls
+	basePath := regexp.MustCompile(`<div id="BasePath">(.*)</div>`).FindStringSubmatch(page)
+	if basePath == nil || html.UnescapeString(basePath[1]) != `"/docker-exporter"` {
+		t.Errorf("homepage base path = %v, want %q", basePath, `"/docker-exporter"`)
+	}
+
+	assets := regexp.MustCompile(`(?:src|href)="(/[^"]*\.(?:js|css))"`).FindAllStringSubmatch(page, -1)
+	if len(assets) == 0 {
+		t.Fatal("homepage links no assets")
+	}
+	for _, asset := range assets {
+		if rec := get(asset[1]); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want %d", asset[1], rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+)
+
+// sysfsPath is the mount point of sysfs used to resolve block device names
+const sysfsPath = "/sys"
+
+type ContainerBlkioDevice struct {
+	Major uint64
+	Minor uint64
+	// device name like sda or nvme0n1, empty if it could not be resolved
+	Name string
+
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadOps    uint64
+	WriteOps   uint64
+}
+
+// MajorMinor returns the device number in the "major:minor" format
+func (d ContainerBlkioDevice) MajorMinor() string {
+	return fmt.Sprintf("%d:%d", d.Major, d.Minor)
+}
+
+type blkioEntry struct {
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+type blkioDeviceKey struct {
+	major uint64
+	minor uint64
+}
+
+// device names are cached for the lifetime of the exporter, as they only change if a disk is replaced
+var (
+	blockDeviceNamesMutex sync.Mutex
+	blockDeviceNames      = make(map[blkioDeviceKey]string)
+)
+
+// parseBlkioStats groups io_service_bytes_recursive and io_serviced_recursive by device, sorted by device number
+func parseBlkioStats(ctx context.Context, containerID string, serviceBytes []blkioEntry, serviced []blkioEntry) []ContainerBlkioDevice {
+	devices := make(map[blkioDeviceKey]*ContainerBlkioDevice)
+	device := func(entry blkioEntry) *ContainerBlkioDevice {
+		key := blkioDeviceKey{major: entry.Major, minor: entry.Minor}
+		d, ok := devices[key]
+		if !ok {
+			d = &ContainerBlkioDevice{Major: entry.Major, Minor: entry.Minor}
+			devices[key] = d
+		}
+		return d
+	}
+	add := func(entries []blkioEntry, ops bool) {
+		for _, entry := range entries {
+			d := device(entry)
+			read, write := &d.ReadBytes, &d.WriteBytes
+			if ops {
+				read, write = &d.ReadOps, &d.WriteOps
+			}
+			// cgroup v1 reports "Read", cgroup v2 "read"
+			switch strings.ToLower(entry.Op) {
+			case "read":
+				*read += entry.Value
+			case "write":
+				*write += entry.Value
+			case "sync", "async", "total", "discard":
+				// cgroup v1 also splits the same io into sync/async and reports the sum
+			default:
+				log.GetLogger().DebugContext(ctx, "Unknown blkio operation", "operation", entry.Op, "container_id", containerID)
+			}
+		}
+	}
+	add(serviceBytes, false)
+	add(serviced, true)
+
+	ret := make([]ContainerBlkioDevice, 0, len(devices))
+	for key, d := range devices {
+		d.Name = blockDeviceName(ctx, key)
+		ret = append(ret, *d)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Major != ret[j].Major {
+			return ret[i].Major < ret[j].Major
+		}
+		return ret[i].Minor < ret[j].Minor
+	})
+	return ret
+}
+
+// blockDeviceName reads DEVNAME from /sys/dev/block/<major>:<minor>/uevent
+func blockDeviceName(ctx context.Context, key blkioDeviceKey) string {
+	blockDeviceNamesMutex.Lock()
+	defer blockDeviceNamesMutex.Unlock()
+	if name, ok := blockDeviceNames[key]; ok {
+		return name
+	}
+
+	name := ""
+	path := filepath.Join(sysfsPath, "dev", "block", fmt.Sprintf("%d:%d", key.major, key.minor), "uevent")
+	file, err := os.Open(path)
+	if err != nil {
+		log.GetLogger().DebugContext(ctx, "Failed to resolve block device name", "path", path, "error", err)
+	} else {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if value, ok := strings.CutPrefix(scanner.Text(), "DEVNAME="); ok {
+				name = value
+				break
+			}
+		}
+	}
+	blockDeviceNames[key] = name
+	return name
+}
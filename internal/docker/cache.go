@@ -9,7 +9,9 @@ import (
 )
 
 type Cache[T any] struct {
-	name            string
+	name string
+	// context of the background refreshes, refreshes are cancelled with it
+	ctx             context.Context
 	mu              sync.Mutex
 	data            T
 	lastUpdated     time.Time
@@ -18,14 +20,31 @@ type Cache[T any] struct {
 	refreshInterval time.Duration
 	clone           func(T) T
 	load            func(ctx context.Context) (T, error)
+
+	// error of the last load, nil if it succeeded
+	lastErr error
+	// consecutive failed loads, no load is started before retryAt after a failure
+	failures int
+	retryAt  time.Time
+
+	// invalidated marks the cache as stale before refreshInterval passed
+	invalidated bool
+	// debounce is the delay between the first Invalidate call and the refresh,
+	// further calls in that window are merged into the same refresh
+	debounce        time.Duration
+	invalidateTimer *time.Timer
+
+	// no refreshes are started once closed
+	closed    bool
+	refreshWg sync.WaitGroup
 }
 
-func NewCache[T any](name string, refreshInterval time.Duration, load func(ctx context.Context) (T, error)) Cache[T] {
-	return NewCacheFull(name, refreshInterval, load, func(t T) T { return t })
+func NewCache[T any](ctx context.Context, name string, refreshInterval time.Duration, debounce time.Duration, load func(ctx context.Context) (T, error)) Cache[T] {
+	return NewCacheFull(ctx, name, refreshInterval, debounce, load, func(t T) T { return t })
 }
 
-func NewCacheFull[T any](name string, refreshInterval time.Duration, load func(ctx context.Context) (T, error), clone func(T) T) Cache[T] {
-	return Cache[T]{name: name, clone: clone, load: load, refreshInterval: refreshInterval}
+func NewCacheFull[T any](ctx context.Context, name string, refreshInterval time.Duration, debounce time.Duration, load func(ctx context.Context) (T, error), clone func(T) T) Cache[T] {
+	return Cache[T]{ctx: ctx, name: name, clone: clone, load: load, refreshInterval: refreshInterval, debounce: debounce}
 }
 
 func copyMap[K comparable, T any](src map[K]T) map[K]T {
@@ -41,8 +60,15 @@ func copyMap[K comparable, T any](src map[K]T) map[K]T {
 
 func (c *Cache[T]) GetValues(ctx context.Context) T {
 	c.mu.Lock()
+	if c.lastErr != nil && time.Now().Before(c.retryAt) {
+		// don't hit an unhealthy daemon on every call, serve what we have until the backoff passed
+		log.GetLogger().Log(ctx, log.LevelTrace, "Cache load failed recently, waiting before retry", "name", c.name, "retry_in", time.Until(c.retryAt))
+		cached := c.clone(c.data)
+		c.mu.Unlock()
+		return cached
+	}
 	cacheExists := !c.lastUpdated.IsZero()
-	stale := !c.lastUpdated.IsZero() && time.Since(c.lastUpdated) >= c.refreshInterval
+	stale := !c.lastUpdated.IsZero() && (c.invalidated || time.Since(c.lastUpdated) >= c.refreshInterval)
 
 	if cacheExists && !stale {
 		// Cache exists and is fresh enough
@@ -81,16 +107,16 @@ func (c *Cache[T]) GetValues(ctx context.Context) T {
 	log.GetLogger().DebugContext(ctx, "Refreshing cache", "name", c.name, "stale", stale, "cacheExists", cacheExists)
 
 	// Need to start a refresh
-	ch := make(chan struct{})
-	c.refreshCh = ch
-	c.refreshing = true
-	// Start background refresh with background context to ensure progress independent of the request context
-	ctx2 := context.Background()
-	go c.loadData(ctx2)
+	ch := c.startRefresh()
 	if !cacheExists {
-		// Block until the initial cache is ready
+		// Block until the initial cache is ready, the load continues for the next call if ctx ends first
 		c.mu.Unlock()
-		<-ch
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			var nilT T
+			return nilT
+		}
 		c.mu.Lock()
 		cached := c.clone(c.data)
 		c.mu.Unlock()
@@ -102,15 +128,134 @@ func (c *Cache[T]) GetValues(ctx context.Context) T {
 	return cached
 }
 
+func (c *Cache[T]) Name() string {
+	return c.name
+}
+
+// Age returns the time since the last successful load, false if the cache was never loaded
+func (c *Cache[T]) Age() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastUpdated.IsZero() {
+		return 0, false
+	}
+	return time.Since(c.lastUpdated), true
+}
+
+// Err returns the error of the last load, nil if it succeeded
+func (c *Cache[T]) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// withData calls fn with the cached data while holding the lock, fn may modify the data in place
+func (c *Cache[T]) withData(fn func(T)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastUpdated.IsZero() {
+		return
+	}
+	fn(c.data)
+}
+
+// SetRefreshInterval changes the age after which the data is refreshed, the cached data is kept
+func (c *Cache[T]) SetRefreshInterval(refreshInterval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshInterval = refreshInterval
+}
+
+// Invalidate schedules a refresh of the cache after the debounce delay.
+//
+// Calls while a refresh is already scheduled are merged into it.
+func (c *Cache[T]) Invalidate(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.invalidateTimer != nil {
+		log.GetLogger().Log(ctx, log.LevelTrace, "Cache invalidation already scheduled", "name", c.name)
+		return
+	}
+	log.GetLogger().DebugContext(ctx, "Scheduling cache invalidation", "name", c.name, "debounce", c.debounce)
+	c.invalidateTimer = time.AfterFunc(c.debounce, c.invalidate)
+}
+
+func (c *Cache[T]) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidateTimer = nil
+	c.invalidated = true
+	// nothing to refresh, the first GetValues call loads the data
+	if c.lastUpdated.IsZero() {
+		return
+	}
+	// a running refresh may have started before the change, the next GetValues call refreshes again
+	if c.refreshing {
+		return
+	}
+	log.GetLogger().Debug("Refreshing invalidated cache", "name", c.name)
+	c.startRefresh()
+}
+
+// startRefresh starts loading the data in the background, c.mu must be held
+func (c *Cache[T]) startRefresh() chan struct{} {
+	ch := make(chan struct{})
+	if c.closed {
+		close(ch)
+		return ch
+	}
+	c.refreshCh = ch
+	c.refreshing = true
+	c.invalidated = false
+	// Start background refresh with the cache context to ensure progress independent of the request context
+	c.refreshWg.Add(1)
+	go func() {
+		defer c.refreshWg.Done()
+		c.loadData(c.ctx)
+	}()
+	return ch
+}
+
+// retryBackoff returns the delay before the next load after c.failures failed loads,
+// it starts at the debounce delay and doubles up to the refresh interval, c.mu must be held
+func (c *Cache[T]) retryBackoff() time.Duration {
+	backoff := max(c.debounce, time.Second)
+	maxBackoff := max(c.refreshInterval, backoff)
+	for i := 1; i < c.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// close stops scheduled refreshes and waits for a running refresh to finish, cancel the cache context first to not wait for the load
+func (c *Cache[T]) close() {
+	c.mu.Lock()
+	c.closed = true
+	if c.invalidateTimer != nil {
+		c.invalidateTimer.Stop()
+		c.invalidateTimer = nil
+	}
+	c.mu.Unlock()
+	c.refreshWg.Wait()
+}
+
 func (c *Cache[T]) loadData(ctx context.Context) {
 	// Perform the expensive call
 	data, err := c.load(ctx)
 
 	// Update cache state
 	c.mu.Lock()
+	c.lastErr = err
 	if err == nil {
 		c.data = data
 		c.lastUpdated = time.Now()
+		c.failures = 0
+	} else {
+		// retry once the backoff passed instead of serving the old data until refreshInterval passed
+		c.invalidated = true
+		c.failures++
+		c.retryAt = time.Now().Add(c.retryBackoff())
+		log.GetLogger().Debug("Cache load failed", "name", c.name, "error", err, "failures", c.failures, "retry_at", c.retryAt)
 	}
 	if c.refreshCh != nil {
 		close(c.refreshCh)
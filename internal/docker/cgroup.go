@@ -0,0 +1,491 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+)
+
+const (
+	// StatsBackendAPI reads container stats from the docker api (one ContainerStats call per container)
+	StatsBackendAPI = "api"
+	// StatsBackendCgroupfs reads container stats directly from the cgroup filesystem
+	StatsBackendCgroupfs = "cgroupfs"
+)
+
+// USER_HZ used by /proc/stat and cpuacct.stat, 100 on all common architectures
+const clockTicksPerSecond = 100
+
+// errCgroupNotFound is returned if no cgroup directory exists for a container
+var errCgroupNotFound = errors.New("container cgroup not found")
+
+// cgroupReader reads container stats from a cgroup v1 or v2 hierarchy mounted at root
+type cgroupReader struct {
+	root     string
+	procRoot string
+	v2       bool
+
+	// the fallback to the docker api is only logged as a warning once, it usually affects every scrape
+	fallbackWarning sync.Once
+}
+
+func newCgroupReader(root string, procRoot string) (*cgroupReader, error) {
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("cgroup root %s: %w", root, err)
+	}
+	// the unified hierarchy has cgroup.controllers in its root
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return &cgroupReader{
+		root:     root,
+		procRoot: procRoot,
+		v2:       err == nil,
+	}, nil
+}
+
+// containerPaths lists the cgroup paths used by the systemd and cgroupfs cgroup drivers
+func containerPaths(containerID string) []string {
+	return []string{
+		filepath.Join("system.slice", "docker-"+containerID+".scope"),
+		filepath.Join("docker", containerID),
+	}
+}
+
+// dir returns the cgroup directory of the container for a v1 controller, the controller is ignored on v2
+func (r *cgroupReader) dir(containerID string, controller string) (string, error) {
+	base := r.root
+	if !r.v2 {
+		base = filepath.Join(r.root, controller)
+	}
+	for _, path := range containerPaths(containerID) {
+		dir := filepath.Join(base, path)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s in %s", errCgroupNotFound, containerID, base)
+}
+
+func (c *Client) getContainerStatsCgroup(ctx context.Context, containerID string, cpu bool) (ContainerStats, error) {
+	r := c.cgroup
+	memDir, err := r.dir(containerID, "memory")
+	if err != nil {
+		return ContainerStats{}, err
+	}
+
+	var cpuStats ContainerCpuStats
+	if cpu {
+		if r.v2 {
+			cpuStats, err = r.cpuStatsV2(memDir)
+		} else {
+			cpuStats, err = r.cpuStatsV1(containerID)
+		}
+		if err != nil {
+			return ContainerStats{}, err
+		}
+		cpuStats.SystemUsageNS, cpuStats.OnlineCpus, err = r.systemCpuUsage()
+		if err != nil {
+			return ContainerStats{}, err
+		}
+		c.addPreviousCpuUsage(containerID, &cpuStats)
+	}
+
+	var mem ContainerMemoryStats
+	if r.v2 {
+		mem, err = r.memoryStatsV2(memDir)
+	} else {
+		mem, err = r.memoryStatsV1(memDir)
+	}
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	mem.Stats[MemoryOOMEvents] = c.containerOOMEvents(containerID)
+
+	pidsDir := memDir
+	if !r.v2 {
+		if pidsDir, err = r.dir(containerID, "pids"); err != nil {
+			return ContainerStats{}, err
+		}
+	}
+	pids, err := readUint(filepath.Join(pidsDir, "pids.current"))
+	if err != nil {
+		return ContainerStats{}, err
+	}
+
+	var serviceBytes, serviced []blkioEntry
+	if r.v2 {
+		serviceBytes, serviced, err = readIOStatV2(filepath.Join(memDir, "io.stat"))
+	} else {
+		var blkioDir string
+		if blkioDir, err = r.dir(containerID, "blkio"); err == nil {
+			if serviceBytes, err = readBlkioV1(filepath.Join(blkioDir, "blkio.throttle.io_service_bytes_recursive")); err == nil {
+				serviced, err = readBlkioV1(filepath.Join(blkioDir, "blkio.throttle.io_serviced_recursive"))
+			}
+		}
+	}
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	blkio := parseBlkioStats(ctx, containerID, serviceBytes, serviced)
+	var blockInputBytes uint64
+	var blockOutputBytes uint64
+	for _, device := range blkio {
+		blockInputBytes += device.ReadBytes
+		blockOutputBytes += device.WriteBytes
+	}
+
+	// network stats are not part of the cgroup, they are read from the network namespace of a process in the container
+	var net ContainerNetStats
+	interfaces, err := r.netStats(memDir)
+	if err != nil {
+		log.GetLogger().DebugContext(ctx, "Failed to read container network stats", "container_id", containerID, "error", err)
+	}
+	for _, iface := range interfaces {
+		net.add(iface)
+	}
+
+	return ContainerStats{
+		PIds:             pids,
+		Cpu:              cpuStats,
+		Memory:           mem,
+		Net:              net,
+		Interfaces:       interfaces,
+		BlockInputBytes:  blockInputBytes,
+		BlockOutputBytes: blockOutputBytes,
+		Blkio:            blkio,
+	}, nil
+}
+
+func (r *cgroupReader) cpuStatsV2(dir string) (ContainerCpuStats, error) {
+	stat, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return ContainerCpuStats{}, err
+	}
+	return ContainerCpuStats{
+		UsageNS:          stat["usage_usec"] * 1000,
+		UsageUserNS:      stat["user_usec"] * 1000,
+		UsageKernelNS:    stat["system_usec"] * 1000,
+		Periods:          stat["nr_periods"],
+		ThrottledPeriods: stat["nr_throttled"],
+		ThrottledTimeNS:  stat["throttled_usec"] * 1000,
+	}, nil
+}
+
+func (r *cgroupReader) cpuStatsV1(containerID string) (ContainerCpuStats, error) {
+	dir, err := r.dir(containerID, "cpuacct")
+	if err != nil {
+		return ContainerCpuStats{}, err
+	}
+	usage, err := readUint(filepath.Join(dir, "cpuacct.usage"))
+	if err != nil {
+		return ContainerCpuStats{}, err
+	}
+	acct, err := readKeyValues(filepath.Join(dir, "cpuacct.stat"))
+	if err != nil {
+		return ContainerCpuStats{}, err
+	}
+	dir, err = r.dir(containerID, "cpu")
+	if err != nil {
+		return ContainerCpuStats{}, err
+	}
+	throttling, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return ContainerCpuStats{}, err
+	}
+	return ContainerCpuStats{
+		UsageNS:          usage,
+		UsageUserNS:      acct["user"] * 1e9 / clockTicksPerSecond,
+		UsageKernelNS:    acct["system"] * 1e9 / clockTicksPerSecond,
+		Periods:          throttling["nr_periods"],
+		ThrottledPeriods: throttling["nr_throttled"],
+		ThrottledTimeNS:  throttling["throttled_time"],
+	}, nil
+}
+
+// systemCpuUsage returns the cpu time of the host in ns and the number of cpus, calculated like docker does from /proc/stat (without steal, guest and guest_nice)
+func (r *cgroupReader) systemCpuUsage() (uint64, uint32, error) {
+	file, err := os.Open(filepath.Join(r.procRoot, "stat"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var total uint64
+	var cpus uint32
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cpus++
+			continue
+		}
+		// user nice system idle iowait irq softirq
+		for _, field := range fields[1:min(len(fields), 8)] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("parse /proc/stat: %w", err)
+			}
+			total += value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return total * 1e9 / clockTicksPerSecond, cpus, nil
+}
+
+func (r *cgroupReader) memoryStatsV2(dir string) (ContainerMemoryStats, error) {
+	usage, err := readUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return ContainerMemoryStats{}, err
+	}
+	limit, err := r.memoryLimit(filepath.Join(dir, "memory.max"))
+	if err != nil {
+		return ContainerMemoryStats{}, err
+	}
+	raw, err := readKeyValues(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return ContainerMemoryStats{}, err
+	}
+	stats := normalizeMemoryStats(raw)
+	return ContainerMemoryStats{
+		UsageBytes: memoryUsage(usage, stats),
+		LimitBytes: limit,
+		Stats:      stats,
+	}, nil
+}
+
+func (r *cgroupReader) memoryStatsV1(dir string) (ContainerMemoryStats, error) {
+	usage, err := readUint(filepath.Join(dir, "memory.usage_in_bytes"))
+	if err != nil {
+		return ContainerMemoryStats{}, err
+	}
+	limit, err := r.memoryLimit(filepath.Join(dir, "memory.limit_in_bytes"))
+	if err != nil {
+		return ContainerMemoryStats{}, err
+	}
+	raw, err := readKeyValues(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return ContainerMemoryStats{}, err
+	}
+	stats := normalizeMemoryStats(raw)
+	if maxUsage, err := readUint(filepath.Join(dir, "memory.max_usage_in_bytes")); err == nil && maxUsage > 0 {
+		stats[MemoryMaxUsage] = maxUsage
+	}
+	if failcnt, err := readUint(filepath.Join(dir, "memory.failcnt")); err == nil {
+		stats[MemoryFailcnt] = failcnt
+	}
+	return ContainerMemoryStats{
+		UsageBytes: memoryUsage(usage, stats),
+		LimitBytes: limit,
+		Stats:      stats,
+	}, nil
+}
+
+// memoryLimit reads a memory limit, unlimited containers report the host memory like the docker api
+func (r *cgroupReader) memoryLimit(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	hostTotal, hostErr := r.hostMemory()
+	if value == "max" {
+		return hostTotal, hostErr
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	// cgroup v1 reports a page aligned max int64 if unlimited
+	if hostErr == nil && limit > hostTotal {
+		return hostTotal, nil
+	}
+	return limit, nil
+}
+
+func (r *cgroupReader) hostMemory() (uint64, error) {
+	meminfo, err := readKeyValues(filepath.Join(r.procRoot, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	// MemTotal is reported in kB
+	return meminfo["MemTotal:"] * 1024, nil
+}
+
+// netStats reads /proc/<pid>/net/dev of the first process in the cgroup, requires the exporter to share the host pid namespace
+func (r *cgroupReader) netStats(dir string) (map[string]ContainerNetStats, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	pid, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	if pid == "" || pid == "0" {
+		return nil, errors.New("no process visible in the container cgroup")
+	}
+	file, err := os.Open(filepath.Join(r.procRoot, pid, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	interfaces := make(map[string]ContainerNetStats)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, values, ok := strings.Cut(scanner.Text(), ":")
+		name = strings.TrimSpace(name)
+		// the docker api does not report the loopback interface either
+		if !ok || name == "lo" {
+			continue
+		}
+		fields := strings.Fields(values)
+		if len(fields) < 12 {
+			continue
+		}
+		var v [12]uint64
+		for i := range v {
+			if v[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+				return nil, fmt.Errorf("parse net/dev: %w", err)
+			}
+		}
+		interfaces[name] = ContainerNetStats{
+			RecvBytes:   v[0],
+			RecvPackets: v[1],
+			RecvErrors:  v[2],
+			RecvDropped: v[3],
+			SendBytes:   v[8],
+			SendPackets: v[9],
+			SendErrors:  v[10],
+			SendDropped: v[11],
+			MacAddress:  r.macAddress(pid, name),
+		}
+	}
+	return interfaces, scanner.Err()
+}
+
+// macAddress reads the MAC address of an interface from the sysfs of the container, "" if it can't be read
+func (r *cgroupReader) macAddress(pid string, iface string) string {
+	data, err := os.ReadFile(filepath.Join(r.procRoot, pid, "root", "sys", "class", "net", iface, "address"))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(string(data)))
+}
+
+// readIOStatV2 converts io.stat lines like "8:0 rbytes=1 wbytes=2 rios=3 wios=4" to blkio entries
+func readIOStatV2(path string) ([]blkioEntry, []blkioEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var serviceBytes, serviced []blkioEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var major, minor uint64
+		if _, err := fmt.Sscanf(fields[0], "%d:%d", &major, &minor); err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			entry := blkioEntry{Major: major, Minor: minor, Value: v}
+			switch key {
+			case "rbytes":
+				entry.Op = "read"
+				serviceBytes = append(serviceBytes, entry)
+			case "wbytes":
+				entry.Op = "write"
+				serviceBytes = append(serviceBytes, entry)
+			case "rios":
+				entry.Op = "read"
+				serviced = append(serviced, entry)
+			case "wios":
+				entry.Op = "write"
+				serviced = append(serviced, entry)
+			}
+		}
+	}
+	return serviceBytes, serviced, scanner.Err()
+}
+
+// readBlkioV1 parses blkio.throttle.* files with lines like "8:0 Read 123" and a trailing "Total 123"
+func readBlkioV1(path string) ([]blkioEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []blkioEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		entry := blkioEntry{Op: fields[1]}
+		if _, err := fmt.Sscanf(fields[0], "%d:%d", &entry.Major, &entry.Minor); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if entry.Value, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// readKeyValues parses files with "key value" lines like memory.stat or cpu.stat
+func readKeyValues(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"sort"
+
+	"github.com/moby/moby/api/types/container"
+)
+
+const (
+	composeProjectLabel         = "com.docker.compose.project"
+	composeServiceLabel         = "com.docker.compose.service"
+	composeContainerNumberLabel = "com.docker.compose.container-number"
+)
+
+// ContainerStates lists all states a container can be in
+var ContainerStates = []container.ContainerState{
+	container.StateCreated,
+	container.StateRunning,
+	container.StatePaused,
+	container.StateRestarting,
+	container.StateRemoving,
+	container.StateExited,
+	container.StateDead,
+}
+
+// ProjectSummary holds totals over all containers of a compose project
+type ProjectSummary struct {
+	Name       string
+	Containers map[container.ContainerState]int
+
+	// sum of cpu usage relative to host CPU cores
+	CpuPercentHost   float64
+	MemoryUsageBytes uint64
+	NetSendBytes     uint64
+	NetRecvBytes     uint64
+}
+
+// PercentHost returns the cpu usage relative to the host CPU cores since the previous sample
+func (s ContainerCpuStats) PercentHost() float64 {
+	if s.SystemUsageNS <= s.PreSystemUsageNS || s.UsageNS < s.PreUsageNS {
+		return 0
+	}
+	return float64(s.UsageNS-s.PreUsageNS) / float64(s.SystemUsageNS-s.PreSystemUsageNS) * 100.0
+}
+
+// SummarizeProjects sums up containers by compose project, containers without a project are ignored.
+//
+// stats is keyed by container ID, containers without stats only count towards the container count.
+func SummarizeProjects(containers []ContainerInfo, stats map[string]ContainerStats) []ProjectSummary {
+	projects := make(map[string]*ProjectSummary)
+	for _, c := range containers {
+		if c.ComposeProject == "" {
+			continue
+		}
+		project, ok := projects[c.ComposeProject]
+		if !ok {
+			project = &ProjectSummary{
+				Name:       c.ComposeProject,
+				Containers: make(map[container.ContainerState]int, len(ContainerStates)),
+			}
+			for _, state := range ContainerStates {
+				project.Containers[state] = 0
+			}
+			projects[c.ComposeProject] = project
+		}
+		project.Containers[c.State]++
+
+		stat, ok := stats[c.ID]
+		if !ok {
+			continue
+		}
+		project.CpuPercentHost += stat.Cpu.PercentHost()
+		project.MemoryUsageBytes += stat.Memory.UsageBytes
+		project.NetSendBytes += stat.Net.SendBytes
+		project.NetRecvBytes += stat.Net.RecvBytes
+	}
+
+	summaries := make([]ProjectSummary, 0, len(projects))
+	for _, project := range projects {
+		summaries = append(summaries, *project)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries
+}
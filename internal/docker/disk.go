@@ -2,6 +2,7 @@ package docker
 
 import (
 	"context"
+	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
 	"github.com/h3rmt/docker-exporter/internal/log"
@@ -19,8 +20,9 @@ type DiskUsage struct {
 	VolumesReclaimable    int64
 }
 
-func loadDiskUsageFunction(c *client.Client) func(ctx context.Context) (DiskUsage, error) {
+func loadDiskUsageFunction(c *client.Client, latencies *apiLatencies) func(ctx context.Context) (DiskUsage, error) {
 	return func(ctx context.Context) (DiskUsage, error) {
+		start := time.Now()
 		data, err := c.DiskUsage(ctx, client.DiskUsageOptions{
 			Containers: true,
 			Images:     true,
@@ -28,6 +30,7 @@ func loadDiskUsageFunction(c *client.Client) func(ctx context.Context) (DiskUsag
 			Volumes:    true,
 			Verbose:    false,
 		})
+		latencies.observe(OpDiskUsage, start)
 		if err != nil {
 			glob.SetError("DiskUsage", &err)
 			log.GetLogger().ErrorContext(ctx, "Failed to disk usage", "error", err)
@@ -48,6 +51,11 @@ func loadDiskUsageFunction(c *client.Client) func(ctx context.Context) (DiskUsag
 	}
 }
 
+// DiskUsageError returns the error of the last disk usage refresh, nil if it succeeded
+func (c *Client) DiskUsageError() error {
+	return c.diskUsageCache.Err()
+}
+
 func (c *Client) Disk(ctx context.Context) DiskUsage {
 	data := c.diskUsageCache.GetValues(ctx)
 	log.GetLogger().Log(ctx, log.LevelTrace, "disk usage cache", "data", data)
@@ -1,18 +1,24 @@
 package docker
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
+	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/moby/moby/api/types/events"
 	"github.com/moby/moby/client"
 )
 
-// TODO add some kind of background removal of old container data
-
 type Client struct {
 	client *client.Client
 
+	// lifetime of the client, background work like snapshots, streams and cache refreshes is cancelled with it
+	ctx context.Context
+	// background goroutines Close waits for
+	background sync.WaitGroup
+
 	// size cache for expensive ContainerList(Size:true)
 	sizeCache Cache[map[string]sizeEntry] // containerID -> sizes
 
@@ -20,23 +26,171 @@ type Client struct {
 	diskUsageCache Cache[DiskUsage]
 
 	cpuStatsRWMutex sync.RWMutex
-	// Cache to calculate cpu usage
+	// Cache to calculate cpu usage, the stream backend uses the previous sample instead
 	cpuStatsCache map[string]cpuEntry // containerID -> sizes
+
+	// docker events subscription state and counters
+	events *eventState
+
+	// restarts seen across inspects for crash-loop detection
+	restarts *restartTracker
+
+	// nil unless stats are read from cgroupfs
+	cgroup *cgroupReader
+	// nil unless stats are streamed
+	streams *statsStreams
+
+	// shared container snapshot of concurrent scrapes
+	snapshots *snapshotState
+
+	// concurrency limit, timeout and error counters of docker requests
+	limiter *requestLimiter
+	// latency histograms of docker api calls
+	latencies *apiLatencies
+
+	// containers to list
+	filter containerFilter
+}
+
+// Config holds the settings of the docker client
+type Config struct {
+	Host                   string
+	SizeCacheDuration      time.Duration
+	DiskUsageCacheDuration time.Duration
+	InvalidationDebounce   time.Duration
+	// snapshots younger than this are reused instead of taking a new one
+	SnapshotMaxAge time.Duration
+
+	// limits of the list, inspect and stats requests of a snapshot, 0 for unlimited
+	MaxConcurrency int
+	RequestTimeout time.Duration
+
+	// a container is crashlooping if it restarted CrashLoopRestarts times in CrashLoopWindow
+	CrashLoopRestarts int
+	CrashLoopWindow   time.Duration
+
+	// StatsBackendAPI, StatsBackendCgroupfs or StatsBackendStream
+	StatsBackend string
+	// mount points of the host cgroup hierarchy and procfs, only used by StatsBackendCgroupfs
+	CgroupRoot string
+	ProcRoot   string
+
+	// containers excluded by the filter are not listed, inspected or queried for stats
+	Filter FilterConfig
 }
 
-func NewDockerClient(host string, sizeCacheDuration time.Duration, diskUsageCacheDuration time.Duration) (*Client, error) {
+// NewDockerClient creates a client whose background work runs until ctx is cancelled, call Close after that
+func NewDockerClient(ctx context.Context, config Config) (*Client, error) {
+	filter, err := newContainerFilter(config.Filter)
+	if err != nil {
+		glob.SetError("NewDockerClient", &err)
+		return nil, err
+	}
 	c, err := client.New(
-		client.WithHost(host),
+		client.WithHost(config.Host),
 		client.WithUserAgent("docker-exporter"),
 	)
 	if err != nil {
 		glob.SetError("NewDockerClient", &err)
 		return nil, err
 	}
-	return &Client{
+	latencies := newAPILatencies()
+	cli := &Client{
 		client:         c,
-		sizeCache:      NewCacheFull("sizeCache", sizeCacheDuration, loadContainerSizeFunction(c), copyMap),
-		diskUsageCache: NewCache("diskUsageCache", diskUsageCacheDuration, loadDiskUsageFunction(c)),
+		ctx:            ctx,
+		sizeCache:      NewCacheFull(ctx, "sizeCache", config.SizeCacheDuration, config.InvalidationDebounce, withTimeout(config.RequestTimeout, loadContainerSizeFunction(c, filter)), copyMap),
+		diskUsageCache: NewCache(ctx, "diskUsageCache", config.DiskUsageCacheDuration, config.InvalidationDebounce, withTimeout(config.RequestTimeout, loadDiskUsageFunction(c, latencies))),
 		cpuStatsCache:  make(map[string]cpuEntry),
-	}, nil
+		events:         newEventState(),
+		restarts:       newRestartTracker(config.CrashLoopRestarts, config.CrashLoopWindow),
+		snapshots:      &snapshotState{maxAge: config.SnapshotMaxAge},
+		limiter:        newRequestLimiter(config.MaxConcurrency, config.RequestTimeout),
+		latencies:      latencies,
+		filter:         filter,
+	}
+	switch config.StatsBackend {
+	case StatsBackendCgroupfs:
+		cli.cgroup, err = newCgroupReader(config.CgroupRoot, config.ProcRoot)
+		if err != nil {
+			glob.SetError("NewDockerClient", &err)
+			return nil, err
+		}
+		log.GetLogger().Info("Reading container stats from cgroupfs", "cgroup_root", config.CgroupRoot, "cgroup_v2", cli.cgroup.v2)
+	case StatsBackendStream:
+		cli.streams = newStatsStreams()
+		cli.OnEvent(cli.handleStreamEvent)
+		log.GetLogger().Info("Streaming container stats")
+	}
+	cli.OnEvent(cli.invalidateCaches)
+	cli.OnEvent(cli.forgetDestroyed)
+	return cli, nil
+}
+
+// Close waits for the background goroutines to exit and closes the connection to docker.
+//
+// The context passed to NewDockerClient must be cancelled first and the client must not be used afterwards.
+func (c *Client) Close() error {
+	c.sizeCache.close()
+	c.diskUsageCache.close()
+	c.background.Wait()
+	return c.client.Close()
+}
+
+// SetCacheDurations changes the refresh intervals of the caches and the max age of snapshots at runtime
+func (c *Client) SetCacheDurations(sizeCache time.Duration, diskUsageCache time.Duration, snapshot time.Duration) {
+	c.sizeCache.SetRefreshInterval(sizeCache)
+	c.diskUsageCache.SetRefreshInterval(diskUsageCache)
+	c.snapshots.mu.Lock()
+	c.snapshots.maxAge = snapshot
+	c.snapshots.mu.Unlock()
+	log.GetLogger().Debug("Changed cache durations", "size_cache", sizeCache, "disk_usage_cache", diskUsageCache, "snapshot", snapshot)
+}
+
+// CacheAges returns the time since the last successful refresh of the caches, caches that were never loaded are left out
+func (c *Client) CacheAges() map[string]time.Duration {
+	ages := make(map[string]time.Duration, 2)
+	if age, ok := c.sizeCache.Age(); ok {
+		ages[c.sizeCache.Name()] = age
+	}
+	if age, ok := c.diskUsageCache.Age(); ok {
+		ages[c.diskUsageCache.Name()] = age
+	}
+	return ages
+}
+
+// invalidateCaches refreshes the caches affected by an event early
+func (c *Client) invalidateCaches(ctx context.Context, msg events.Message) {
+	var sizes, disk bool
+	switch msg.Type {
+	case events.ContainerEventType:
+		switch msg.Action {
+		case events.ActionCreate, events.ActionDestroy, events.ActionPrune:
+			sizes = true
+			disk = true
+		}
+	case events.ImageEventType:
+		switch msg.Action {
+		case events.ActionPull, events.ActionDelete, events.ActionLoad, events.ActionImport, events.ActionPrune:
+			disk = true
+		}
+	case events.VolumeEventType:
+		switch msg.Action {
+		case events.ActionCreate, events.ActionDestroy, events.ActionPrune:
+			disk = true
+		}
+	case events.BuilderEventType:
+		if msg.Action == events.ActionPrune {
+			disk = true
+		}
+	}
+
+	if sizes || disk {
+		log.GetLogger().Log(ctx, log.LevelTrace, "Invalidating caches", "type", msg.Type, "action", msg.Action, "size_cache", sizes, "disk_usage_cache", disk)
+	}
+	if sizes {
+		c.sizeCache.Invalidate(ctx)
+	}
+	if disk {
+		c.diskUsageCache.Invalidate(ctx)
+	}
 }
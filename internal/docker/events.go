@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h3rmt/docker-exporter/internal/glob"
+	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/moby/moby/api/types/events"
+	"github.com/moby/moby/client"
+)
+
+const (
+	eventsMinBackoff = 1 * time.Second
+	eventsMaxBackoff = 30 * time.Second
+)
+
+// EventHandler is called for every event received from the docker daemon
+type EventHandler func(ctx context.Context, msg events.Message)
+
+// EventCounts holds the number of events seen per action since the exporter started
+type EventCounts struct {
+	Container map[string]uint64 // action -> count
+	Image     map[string]uint64 // action -> count
+}
+
+type eventState struct {
+	mu       sync.RWMutex
+	handlers []EventHandler
+	counts   EventCounts
+	// oom events per container
+	oomEvents map[string]uint64
+	// timestamp of the last received event, used to resume after reconnecting
+	lastTimeNano int64
+}
+
+func newEventState() *eventState {
+	return &eventState{
+		counts: EventCounts{
+			Container: make(map[string]uint64),
+			Image:     make(map[string]uint64),
+		},
+		oomEvents: make(map[string]uint64),
+	}
+}
+
+// OnEvent registers a handler that is called for every received event.
+//
+// Handlers are called synchronously from the event loop and should not block.
+func (c *Client) OnEvent(handler EventHandler) {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+	c.events.handlers = append(c.events.handlers, handler)
+}
+
+// EventCounts returns a copy of the event counters
+func (c *Client) EventCounts() EventCounts {
+	c.events.mu.RLock()
+	defer c.events.mu.RUnlock()
+	return EventCounts{
+		Container: copyMap(c.events.counts.Container),
+		Image:     copyMap(c.events.counts.Image),
+	}
+}
+
+// containerOOMEvents returns the number of oom events of the container since the exporter started
+func (c *Client) containerOOMEvents(containerID string) uint64 {
+	c.events.mu.RLock()
+	defer c.events.mu.RUnlock()
+	return c.events.oomEvents[containerID]
+}
+
+// WatchEvents subscribes to the docker events stream and blocks until ctx is cancelled.
+//
+// The subscription is re-established after errors, resuming from the timestamp of the last received event.
+func (c *Client) WatchEvents(ctx context.Context) {
+	backoff := eventsMinBackoff
+	for {
+		received, err := c.watchEvents(ctx)
+		if ctx.Err() != nil {
+			glob.SetError("WatchEvents", nil)
+			return
+		}
+		if received {
+			backoff = eventsMinBackoff
+		}
+		if err != nil {
+			glob.SetError("WatchEvents", &err)
+			log.GetLogger().WarnContext(ctx, "Docker events stream failed, reconnecting", "error", err, "backoff", backoff)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			glob.SetError("WatchEvents", nil)
+			return
+		}
+		backoff = min(backoff*2, eventsMaxBackoff)
+	}
+}
+
+// watchEvents reads events until the stream fails, returns if any event was received
+func (c *Client) watchEvents(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.events.mu.RLock()
+	since := c.events.lastTimeNano
+	c.events.mu.RUnlock()
+
+	options := client.EventsListOptions{}
+	if since > 0 {
+		// resume 1ns after the last event to not count it twice
+		since++
+		options.Since = fmt.Sprintf("%d.%09d", since/int64(time.Second), since%int64(time.Second))
+	}
+	log.GetLogger().DebugContext(ctx, "Subscribing to docker events", "since", options.Since)
+
+	res := c.client.Events(ctx, options)
+	received := false
+	for {
+		select {
+		case msg := <-res.Messages:
+			if !received {
+				received = true
+				glob.SetError("WatchEvents", nil)
+			}
+			c.handleEvent(ctx, msg)
+		case err := <-res.Err:
+			if err == nil {
+				err = errors.New("docker events stream closed")
+			}
+			return received, err
+		}
+	}
+}
+
+func (c *Client) handleEvent(ctx context.Context, msg events.Message) {
+	action := eventAction(msg.Action)
+	log.GetLogger().Log(ctx, log.LevelTrace, "Received docker event", "type", msg.Type, "action", msg.Action, "actor_id", msg.Actor.ID)
+
+	c.events.mu.Lock()
+	switch msg.Type {
+	case events.ContainerEventType:
+		c.events.counts.Container[action]++
+		if msg.Action == events.ActionOOM {
+			c.events.oomEvents[msg.Actor.ID]++
+		}
+	case events.ImageEventType:
+		c.events.counts.Image[action]++
+	}
+	if msg.TimeNano > c.events.lastTimeNano {
+		c.events.lastTimeNano = msg.TimeNano
+	}
+	handlers := c.events.handlers
+	c.events.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(ctx, msg)
+	}
+}
+
+// eventAction strips the free-form suffix of actions like "health_status: healthy" or "exec_start: sh"
+func eventAction(action events.Action) string {
+	a, _, _ := strings.Cut(string(action), ":")
+	return strings.TrimSpace(a)
+}
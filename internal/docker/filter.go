@@ -0,0 +1,154 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/moby/moby/api/types/container"
+)
+
+// FilterConfig selects the containers that are listed, excluded containers are never inspected or queried for stats.
+//
+// Empty fields don't filter, excludes take precedence over includes.
+type FilterConfig struct {
+	// regular expressions matched against all names of a container
+	NameInclude string
+	NameExclude string
+	// label selectors "key" or "key=value", a container must match all includes and is excluded if it matches any exclude
+	LabelInclude []string
+	LabelExclude []string
+	// regular expressions matched against the image a container was created from (e.g. "nginx:latest")
+	ImageInclude string
+	ImageExclude string
+	// states of the containers to list (running, exited, ...)
+	States []string
+}
+
+type labelSelector struct {
+	key string
+	// nil matches any value
+	value *string
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	value, ok := labels[s.key]
+	return ok && (s.value == nil || *s.value == value)
+}
+
+// containerFilter is the compiled FilterConfig, the zero value matches all containers
+type containerFilter struct {
+	nameInclude  *regexp.Regexp
+	nameExclude  *regexp.Regexp
+	labelInclude []labelSelector
+	labelExclude []labelSelector
+	imageInclude *regexp.Regexp
+	imageExclude *regexp.Regexp
+	states       []container.ContainerState
+}
+
+var containerStates = []container.ContainerState{
+	container.StateCreated, container.StateRunning, container.StatePaused, container.StateRestarting,
+	container.StateRemoving, container.StateExited, container.StateDead,
+}
+
+func newContainerFilter(config FilterConfig) (containerFilter, error) {
+	var f containerFilter
+	var err error
+	compile := func(flag string, expr string) *regexp.Regexp {
+		if expr == "" || err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		re, err = regexp.Compile(expr)
+		if err != nil {
+			err = fmt.Errorf("invalid %s regex %q: %w", flag, expr, err)
+		}
+		return re
+	}
+	f.nameInclude = compile("name include", config.NameInclude)
+	f.nameExclude = compile("name exclude", config.NameExclude)
+	f.imageInclude = compile("image include", config.ImageInclude)
+	f.imageExclude = compile("image exclude", config.ImageExclude)
+	if err != nil {
+		return containerFilter{}, err
+	}
+
+	if f.labelInclude, err = parseLabelSelectors(config.LabelInclude); err != nil {
+		return containerFilter{}, err
+	}
+	if f.labelExclude, err = parseLabelSelectors(config.LabelExclude); err != nil {
+		return containerFilter{}, err
+	}
+
+	for _, state := range config.States {
+		state := container.ContainerState(strings.ToLower(state))
+		if !slices.Contains(containerStates, state) {
+			return containerFilter{}, fmt.Errorf("invalid container state %q (want one of %v)", state, containerStates)
+		}
+		f.states = append(f.states, state)
+	}
+	return f, nil
+}
+
+func parseLabelSelectors(selectors []string) ([]labelSelector, error) {
+	parsed := make([]labelSelector, 0, len(selectors))
+	for _, selector := range selectors {
+		key, value, hasValue := strings.Cut(selector, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid label selector %q (want key or key=value)", selector)
+		}
+		s := labelSelector{key: key}
+		if hasValue {
+			s.value = &value
+		}
+		parsed = append(parsed, s)
+	}
+	return parsed, nil
+}
+
+// matches returns if the container passes the filter
+func (f containerFilter) matches(info ContainerInfo) bool {
+	if len(f.states) > 0 && !slices.Contains(f.states, info.State) {
+		return false
+	}
+	if f.nameInclude != nil && !slices.ContainsFunc(info.Names, f.nameInclude.MatchString) {
+		return false
+	}
+	if f.nameExclude != nil && slices.ContainsFunc(info.Names, f.nameExclude.MatchString) {
+		return false
+	}
+	if f.imageInclude != nil && !f.imageInclude.MatchString(info.Image) {
+		return false
+	}
+	if f.imageExclude != nil && f.imageExclude.MatchString(info.Image) {
+		return false
+	}
+	for _, s := range f.labelInclude {
+		if !s.matches(info.Labels) {
+			return false
+		}
+	}
+	for _, s := range f.labelExclude {
+		if s.matches(info.Labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterContainers removes the containers that don't pass the filter
+func (c *Client) filterContainers(ctx context.Context, containers []ContainerInfo) []ContainerInfo {
+	filtered := containers[:0]
+	for _, info := range containers {
+		if c.filter.matches(info) {
+			filtered = append(filtered, info)
+		} else {
+			log.GetLogger().Log(ctx, log.LevelTrace, "Filtered out container", "container_id", info.ID, "names", info.Names, "image", info.Image)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/events"
+)
+
+func TestNewContainerFilterInvalid(t *testing.T) {
+	tests := map[string]FilterConfig{
+		"name regex":     {NameInclude: "("},
+		"image regex":    {ImageExclude: "[a-"},
+		"empty label":    {LabelInclude: []string{"=value"}},
+		"unknown state":  {States: []string{"sleeping"}},
+		"exclude regex":  {NameExclude: "*"},
+		"exclude labels": {LabelExclude: []string{""}},
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newContainerFilter(config); err == nil {
+				t.Fatalf("newContainerFilter(%+v) = nil error, want error", config)
+			}
+		})
+	}
+}
+
+func TestContainerFilterMatches(t *testing.T) {
+	web := ContainerInfo{
+		ID:     "web",
+		Names:  []string{"web-1", "proxy"},
+		Image:  "nginx:latest",
+		State:  container.StateRunning,
+		Labels: map[string]string{"env": "prod", "monitor": ""},
+	}
+	db := ContainerInfo{
+		ID:     "db",
+		Names:  []string{"db-1"},
+		Image:  "postgres:16",
+		State:  container.StateExited,
+		Labels: map[string]string{"env": "dev"},
+	}
+
+	tests := []struct {
+		name   string
+		config FilterConfig
+		want   []string
+	}{
+		{name: "empty", config: FilterConfig{}, want: []string{"web", "db"}},
+		{name: "name include any name", config: FilterConfig{NameInclude: "^proxy$"}, want: []string{"web"}},
+		{name: "name exclude", config: FilterConfig{NameExclude: "^db-"}, want: []string{"web"}},
+		{name: "exclude takes precedence", config: FilterConfig{NameInclude: "-1$", NameExclude: "^web"}, want: []string{"db"}},
+		{name: "image include", config: FilterConfig{ImageInclude: "^postgres:"}, want: []string{"db"}},
+		{name: "image exclude", config: FilterConfig{ImageExclude: "nginx"}, want: []string{"db"}},
+		{name: "label key", config: FilterConfig{LabelInclude: []string{"monitor"}}, want: []string{"web"}},
+		{name: "label key with any value", config: FilterConfig{LabelInclude: []string{"env"}}, want: []string{"web", "db"}},
+		{name: "label key=value", config: FilterConfig{LabelInclude: []string{"env=dev"}}, want: []string{"db"}},
+		{name: "label key= matches empty value", config: FilterConfig{LabelInclude: []string{"monitor="}}, want: []string{"web"}},
+		{name: "all label includes", config: FilterConfig{LabelInclude: []string{"env=prod", "monitor"}}, want: []string{"web"}},
+		{name: "any label exclude", config: FilterConfig{LabelExclude: []string{"missing", "env=prod"}}, want: []string{"db"}},
+		{name: "state", config: FilterConfig{States: []string{"running"}}, want: []string{"web"}},
+		{name: "state case insensitive", config: FilterConfig{States: []string{"Exited", "dead"}}, want: []string{"db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newContainerFilter(tt.config)
+			if err != nil {
+				t.Fatalf("newContainerFilter(%+v): %v", tt.config, err)
+			}
+			c := &Client{filter: filter}
+			var got []string
+			for _, info := range c.filterContainers(context.Background(), []ContainerInfo{web, db}) {
+				got = append(got, info.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterContainers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerFilterMatchesEvent(t *testing.T) {
+	msg := events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionStart,
+		Actor: events.Actor{
+			ID:         "web",
+			Attributes: map[string]string{"name": "web-1", "image": "nginx:latest", "env": "prod"},
+		},
+	}
+	tests := []struct {
+		name   string
+		config FilterConfig
+		want   bool
+	}{
+		{name: "name", config: FilterConfig{NameInclude: "^web-1$"}, want: true},
+		{name: "image", config: FilterConfig{ImageExclude: "^nginx"}, want: false},
+		{name: "label", config: FilterConfig{LabelInclude: []string{"env=prod"}}, want: true},
+		// name and image are attributes of the event, not labels of the container
+		{name: "name is no label", config: FilterConfig{LabelExclude: []string{"name=web-1"}}, want: true},
+		{name: "image is no label", config: FilterConfig{LabelInclude: []string{"image"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newContainerFilter(tt.config)
+			if err != nil {
+				t.Fatalf("newContainerFilter(%+v): %v", tt.config, err)
+			}
+			if got := filter.matches(containerInfoFromEvent(msg)); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
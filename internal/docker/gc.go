@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/h3rmt/docker-exporter/internal/glob"
+	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/moby/moby/api/types/events"
+	"github.com/moby/moby/client"
+)
+
+// RunGC periodically removes the state kept for containers that no longer exist or are filtered out and blocks until ctx is cancelled.
+//
+// State of destroyed containers is also removed as soon as the destroy event is received,
+// the periodic run catches containers removed while the events stream was disconnected.
+func (c *Client) RunGC(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.collectGarbage(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) collectGarbage(ctx context.Context) {
+	containers, err := c.client.ContainerList(ctx, client.ContainerListOptions{All: true})
+	if err != nil {
+		glob.SetError("ContainerGC", &err)
+		log.GetLogger().WarnContext(ctx, "Failed to list containers for garbage collection", "error", err)
+		return
+	}
+	glob.SetError("ContainerGC", nil)
+
+	// containers excluded by the filter (e.g. after a state change) are forgotten like deleted ones
+	existing := make(map[string]struct{}, len(containers.Items))
+	for _, item := range containers.Items {
+		if c.filter.matches(containerInfoFromSummary(item)) {
+			existing[item.ID] = struct{}{}
+		}
+	}
+	removed := c.forgetContainers(ctx, func(containerID string) bool {
+		_, ok := existing[containerID]
+		return !ok
+	})
+	log.GetLogger().DebugContext(ctx, "Removed state of deleted and filtered out containers", "removed", removed, "tracked", c.TrackedContainers())
+}
+
+// forgetDestroyed removes the state of a container once it is destroyed
+func (c *Client) forgetDestroyed(ctx context.Context, msg events.Message) {
+	if msg.Type != events.ContainerEventType || msg.Action != events.ActionDestroy {
+		return
+	}
+	c.forgetContainers(ctx, func(containerID string) bool {
+		return containerID == msg.Actor.ID
+	})
+}
+
+// forgetContainers removes all per-container state of the containers matching remove, returns the number of removed containers
+func (c *Client) forgetContainers(ctx context.Context, remove func(containerID string) bool) int {
+	removed := make(map[string]struct{})
+	forget := func(containerID string) bool {
+		if remove(containerID) {
+			removed[containerID] = struct{}{}
+			return true
+		}
+		return false
+	}
+
+	c.cpuStatsRWMutex.Lock()
+	for id := range c.cpuStatsCache {
+		if forget(id) {
+			delete(c.cpuStatsCache, id)
+		}
+	}
+	c.cpuStatsRWMutex.Unlock()
+
+	c.events.mu.Lock()
+	for id := range c.events.oomEvents {
+		if forget(id) {
+			delete(c.events.oomEvents, id)
+		}
+	}
+	c.events.mu.Unlock()
+
+	c.restarts.mu.Lock()
+	for id := range c.restarts.containers {
+		if forget(id) {
+			delete(c.restarts.containers, id)
+		}
+	}
+	c.restarts.mu.Unlock()
+
+	c.sizeCache.withData(func(sizes map[string]sizeEntry) {
+		for id := range sizes {
+			if forget(id) {
+				delete(sizes, id)
+			}
+		}
+	})
+
+	if c.streams != nil {
+		c.streams.mu.Lock()
+		for id, s := range c.streams.streams {
+			if forget(id) {
+				s.cancel()
+				delete(c.streams.streams, id)
+			}
+		}
+		c.streams.mu.Unlock()
+	}
+
+	if len(removed) > 0 {
+		log.GetLogger().Log(ctx, log.LevelTrace, "Forgot container state", "count", len(removed))
+	}
+	return len(removed)
+}
+
+// TrackedContainers returns the number of containers the client keeps state for
+func (c *Client) TrackedContainers() int {
+	tracked := make(map[string]struct{})
+
+	c.cpuStatsRWMutex.RLock()
+	for id := range c.cpuStatsCache {
+		tracked[id] = struct{}{}
+	}
+	c.cpuStatsRWMutex.RUnlock()
+
+	c.events.mu.RLock()
+	for id := range c.events.oomEvents {
+		tracked[id] = struct{}{}
+	}
+	c.events.mu.RUnlock()
+
+	c.restarts.mu.Lock()
+	for id := range c.restarts.containers {
+		tracked[id] = struct{}{}
+	}
+	c.restarts.mu.Unlock()
+
+	c.sizeCache.withData(func(sizes map[string]sizeEntry) {
+		for id := range sizes {
+			tracked[id] = struct{}{}
+		}
+	})
+
+	if c.streams != nil {
+		c.streams.mu.Lock()
+		for id := range c.streams.streams {
+			tracked[id] = struct{}{}
+		}
+		c.streams.mu.Unlock()
+	}
+	return len(tracked)
+}
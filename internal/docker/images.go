@@ -2,6 +2,7 @@ package docker
 
 import (
 	"context"
+	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
 	"github.com/h3rmt/docker-exporter/internal/log"
@@ -27,10 +28,12 @@ func (c *Client) ListAllImages(ctx context.Context) ([]ImageInfo, error) {
 }
 
 func (c *Client) listAllImages(ctx context.Context) ([]ImageInfo, error) {
+	start := time.Now()
 	images, err := c.client.ImageList(ctx, client.ImageListOptions{
 		All:        false,
 		SharedSize: false,
 	})
+	c.latencies.observe(OpImageList, start)
 	if err != nil {
 		return nil, err
 	}
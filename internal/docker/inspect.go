@@ -3,6 +3,8 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
@@ -15,11 +17,18 @@ type ContainerInfo struct {
 	ID          string
 	Names       []string
 	ImageID     string
+	Image       string
 	Command     string
 	Ports       []container.PortSummary
 	NetworkMode string
 	Created     int64
 	State       container.ContainerState
+	Labels      map[string]string
+
+	// docker compose labels, empty if not started by compose
+	ComposeProject         string
+	ComposeService         string
+	ComposeContainerNumber string
 }
 
 type ContainerInspect struct {
@@ -30,27 +39,70 @@ type ContainerInspect struct {
 	SizeRootFs   int64
 	SizeRw       int64
 	NanoCpus     int64
+	OOMKilled    bool
+	AutoRemove   bool
+
+	// CFS quota and period in microseconds
+	CPUQuota   int64
+	CPUPeriod  int64
+	CPUShares  int64
+	CpusetCpus string
+	CpusetMems string
+	// names of the docker networks the container is connected to, sorted
+	Networks []string
+	// docker network names by the lowercase MAC address of the container endpoint
+	NetworkMacs map[string]string
+
+	// error message of the last start, empty if none
+	Error string
+
+	RestartPolicyName     string
+	RestartPolicyMaxRetry int
+
+	// NoHealthcheck if the container has no health check configured
+	HealthStatus        container.HealthStatus
+	HealthFailingStreak int
+	// most recent health check probe, nil if no probe ran yet
+	HealthLastProbe *HealthProbe
+}
+
+type HealthProbe struct {
+	Duration time.Duration
+	ExitCode int
 }
 
 type Inspect struct {
-	RestartCount int              `json:"RestartCount"`
-	State        *container.State `json:"State"`
-	SizeRw       *int64           `json:"SizeRw,omitempty"`
-	SizeRootFs   *int64           `json:"SizeRootFs,omitempty"`
-	HostConfig   struct {
+	RestartCount    int              `json:"RestartCount"`
+	State           *container.State `json:"State"`
+	SizeRw          *int64           `json:"SizeRw,omitempty"`
+	SizeRootFs      *int64           `json:"SizeRootFs,omitempty"`
+	NetworkSettings *struct {
+		Networks map[string]struct {
+			MacAddress string `json:"MacAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+	HostConfig struct {
 		// only this looks like the real value to be used for limiting cpu
 		NanoCPUs  int64 `json:"NanoCpus"`  // CPU quota in units of 10<sup>-9</sup> CPUs.
 		CPUShares int64 `json:"CpuShares"` // CPU shares (relative weight vs. other containers)
 
 		// Applicable to UNIX platforms
-		CPUPeriod          int64 `json:"CpuPeriod"`          // CPU CFS (Completely Fair Scheduler) period
-		CPUQuota           int64 `json:"CpuQuota"`           // CPU CFS (Completely Fair Scheduler) quota
-		CPURealtimePeriod  int64 `json:"CpuRealtimePeriod"`  // CPU real-time period
-		CPURealtimeRuntime int64 `json:"CpuRealtimeRuntime"` // CPU real-time runtime
+		CPUPeriod          int64  `json:"CpuPeriod"`          // CPU CFS (Completely Fair Scheduler) period
+		CPUQuota           int64  `json:"CpuQuota"`           // CPU CFS (Completely Fair Scheduler) quota
+		CPURealtimePeriod  int64  `json:"CpuRealtimePeriod"`  // CPU real-time period
+		CPURealtimeRuntime int64  `json:"CpuRealtimeRuntime"` // CPU real-time runtime
+		CpusetCpus         string `json:"CpusetCpus"`         // CpusetCpus 0-2, 0,1
+		CpusetMems         string `json:"CpusetMems"`         // CpusetMems 0-2, 0,1
 
 		// Applicable to Windows
 		CPUCount   int64 `json:"CpuCount"`   // CPU count
 		CPUPercent int64 `json:"CpuPercent"` // CPU percent
+
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+		AutoRemove bool `json:"AutoRemove"`
 	} `json:"HostConfig"`
 }
 
@@ -61,6 +113,7 @@ func (c *Client) InspectContainer(ctx context.Context, containerID string, size
 		return ContainerInspect{}, err
 	}
 	glob.SetError("InspectContainer", nil)
+	c.restarts.observe(containerID, inspect.RestartCount, inspect.StartedAt)
 	return inspect, nil
 }
 
@@ -69,22 +122,26 @@ type sizeEntry struct {
 	SizeRw     int64
 }
 
-func loadContainerSizeFunction(c *client.Client) func(ctx context.Context) (map[string]sizeEntry, error) {
+// loadContainerSizeFunction lists the sizes of all containers that pass filter
+func loadContainerSizeFunction(c *client.Client, filter containerFilter) func(ctx context.Context) (map[string]sizeEntry, error) {
 	return func(ctx context.Context) (map[string]sizeEntry, error) {
 		containers, err := c.ContainerList(ctx, client.ContainerListOptions{
 			All:  true,
 			Size: true,
 		})
-		// Prepare results
-		sizes := make(map[string]sizeEntry)
 		if err != nil {
+			// the cache keeps the previous sizes and retries after a backoff
 			glob.SetError("refreshSizes", &err)
 			log.GetLogger().ErrorContext(ctx, "Failed to refresh container sizes", "error", err)
-		} else {
-			glob.SetError("refreshSizes", nil)
-			for _, item := range containers.Items {
-				sizes[item.ID] = sizeEntry{SizeRootFs: item.SizeRootFs, SizeRw: item.SizeRw}
+			return nil, err
+		}
+		glob.SetError("refreshSizes", nil)
+		sizes := make(map[string]sizeEntry, len(containers.Items))
+		for _, item := range containers.Items {
+			if !filter.matches(containerInfoFromSummary(item)) {
+				continue
 			}
+			sizes[item.ID] = sizeEntry{SizeRootFs: item.SizeRootFs, SizeRw: item.SizeRw}
 		}
 		return sizes, nil
 	}
@@ -104,9 +161,11 @@ func (c *Client) inspectContainer(ctx context.Context, containerID string, size
 		}
 	}
 
+	start := time.Now()
 	inspect, err := c.client.ContainerInspect(ctx, containerID, client.ContainerInspectOptions{
 		Size: size && sizeRootFs == 0,
 	})
+	c.latencies.observe(OpInspect, start)
 	if err != nil {
 		return ContainerInspect{}, err
 	}
@@ -125,10 +184,44 @@ func (c *Client) inspectContainer(ctx context.Context, containerID string, size
 		FinishedAt:   parseTimeOrEmpty(ret.State.FinishedAt),
 		RestartCount: ret.RestartCount,
 		NanoCpus:     ret.HostConfig.NanoCPUs,
+		CPUQuota:     ret.HostConfig.CPUQuota,
+		CPUPeriod:    ret.HostConfig.CPUPeriod,
+		CPUShares:    ret.HostConfig.CPUShares,
+		CpusetCpus:   ret.HostConfig.CpusetCpus,
+		CpusetMems:   ret.HostConfig.CpusetMems,
 		SizeRootFs:   sizeRootFs,
 		SizeRw:       sizeRw,
+		OOMKilled:    ret.State.OOMKilled,
+		Error:        ret.State.Error,
+		AutoRemove:   ret.HostConfig.AutoRemove,
+		HealthStatus: container.NoHealthcheck,
+
+		RestartPolicyName:     ret.HostConfig.RestartPolicy.Name,
+		RestartPolicyMaxRetry: ret.HostConfig.RestartPolicy.MaximumRetryCount,
+	}
+	if ret.NetworkSettings != nil {
+		cInspect.NetworkMacs = make(map[string]string, len(ret.NetworkSettings.Networks))
+		for name, network := range ret.NetworkSettings.Networks {
+			cInspect.Networks = append(cInspect.Networks, name)
+			if network.MacAddress != "" {
+				cInspect.NetworkMacs[strings.ToLower(network.MacAddress)] = name
+			}
+		}
+		sort.Strings(cInspect.Networks)
+	}
+	if health := ret.State.Health; health != nil {
+		cInspect.HealthStatus = health.Status
+		cInspect.HealthFailingStreak = health.FailingStreak
+		// log is ordered oldest first
+		if len(health.Log) > 0 && health.Log[len(health.Log)-1] != nil {
+			last := health.Log[len(health.Log)-1]
+			cInspect.HealthLastProbe = &HealthProbe{
+				Duration: last.End.Sub(last.Start),
+				ExitCode: last.ExitCode,
+			}
+		}
 	}
-	log.GetLogger().Log(ctx, log.LevelTrace, "Inspected container", "container_id", containerID, "exit_code", cInspect.ExitCode, "restart_count", cInspect.RestartCount)
+	log.GetLogger().Log(ctx, log.LevelTrace, "Inspected container", "container_id", containerID, "exit_code", cInspect.ExitCode, "restart_count", cInspect.RestartCount, "health", cInspect.HealthStatus)
 	return cInspect, nil
 }
 
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"sync"
+	"time"
+)
+
+// operations of the docker api latency histograms
+const (
+	OpList      = "list"
+	OpInspect   = "inspect"
+	OpStats     = "stats"
+	OpDiskUsage = "disk_usage"
+	OpImageList = "image_list"
+)
+
+// LatencyBuckets are the upper bounds in seconds of the docker api latency histograms
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type LatencyHistogram struct {
+	Count uint64
+	// sum of all durations in seconds
+	Sum float64
+	// cumulative number of requests per upper bound of LatencyBuckets
+	Buckets map[float64]uint64
+}
+
+type apiLatencies struct {
+	mu  sync.Mutex
+	ops map[string]*LatencyHistogram
+}
+
+func newAPILatencies() *apiLatencies {
+	return &apiLatencies{ops: make(map[string]*LatencyHistogram)}
+}
+
+// observe records the duration of a docker api call started at start
+func (l *apiLatencies) observe(op string, start time.Time) {
+	seconds := time.Since(start).Seconds()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.ops[op]
+	if !ok {
+		h = &LatencyHistogram{Buckets: make(map[float64]uint64, len(LatencyBuckets))}
+		l.ops[op] = h
+	}
+	h.Count++
+	h.Sum += seconds
+	for _, bound := range LatencyBuckets {
+		if seconds <= bound {
+			h.Buckets[bound]++
+		}
+	}
+}
+
+// APILatencies returns the latency histograms of the docker api calls by operation
+func (c *Client) APILatencies() map[string]LatencyHistogram {
+	c.latencies.mu.Lock()
+	defer c.latencies.mu.Unlock()
+	ret := make(map[string]LatencyHistogram, len(c.latencies.ops))
+	for op, h := range c.latencies.ops {
+		ret[op] = LatencyHistogram{Count: h.Count, Sum: h.Sum, Buckets: copyMap(h.Buckets)}
+	}
+	return ret
+}
@@ -2,9 +2,11 @@ package docker
 
 import (
 	"context"
+	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
 	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/client"
 )
 
@@ -19,35 +21,50 @@ func (c *Client) ListAllRunningContainers(ctx context.Context) ([]ContainerInfo,
 }
 
 func (c *Client) listAllRunningContainers(ctx context.Context) ([]ContainerInfo, error) {
+	start := time.Now()
 	containers, err := c.client.ContainerList(ctx, client.ContainerListOptions{
 		All:  true,
 		Size: false,
 	})
+	c.latencies.observe(OpList, start)
 	if err != nil {
 		return nil, err
 	}
 	containerInfos := make([]ContainerInfo, len(containers.Items))
 	for i, c := range containers.Items {
+		containerInfos[i] = containerInfoFromSummary(c)
+		log.GetLogger().Log(ctx, log.LevelTrace, "Listed container", "container_id", containerInfos[i].ID, "names", containerInfos[i].Names, "state", containerInfos[i].State, "compose_project", containerInfos[i].ComposeProject)
+	}
+	containerInfos = c.filterContainers(ctx, containerInfos)
+	if c.streams != nil {
+		c.syncStatsStreams(ctx, containerInfos)
+	}
+	return containerInfos, nil
+}
 
-		names := make([]string, len(c.Names))
-		for j, name := range c.Names {
-			if len(name) > 0 && name[0] == '/' {
-				names[j] = name[1:]
-			} else {
-				names[j] = name
-			}
+func containerInfoFromSummary(c container.Summary) ContainerInfo {
+	names := make([]string, len(c.Names))
+	for j, name := range c.Names {
+		if len(name) > 0 && name[0] == '/' {
+			names[j] = name[1:]
+		} else {
+			names[j] = name
 		}
-		containerInfos[i] = ContainerInfo{
-			ID:          c.ID,
-			Names:       names,
-			ImageID:     c.ImageID,
-			Command:     c.Command,
-			Ports:       c.Ports,
-			NetworkMode: c.HostConfig.NetworkMode,
-			State:       c.State,
-			Created:     c.Created,
-		}
-		log.GetLogger().Log(ctx, log.LevelTrace, "Listed container", "container_id", containerInfos[i].ID, "names", containerInfos[i].Names, "state", containerInfos[i].State)
 	}
-	return containerInfos, nil
+	return ContainerInfo{
+		ID:          c.ID,
+		Names:       names,
+		ImageID:     c.ImageID,
+		Image:       c.Image,
+		Command:     c.Command,
+		Ports:       c.Ports,
+		NetworkMode: c.HostConfig.NetworkMode,
+		State:       c.State,
+		Created:     c.Created,
+		Labels:      c.Labels,
+
+		ComposeProject:         c.Labels[composeProjectLabel],
+		ComposeService:         c.Labels[composeServiceLabel],
+		ComposeContainerNumber: c.Labels[composeContainerNumberLabel],
+	}
 }
@@ -0,0 +1,63 @@
+package docker
+
+// normalized keys of ContainerMemoryStats.Stats
+const (
+	MemoryAnon         = "anon"
+	MemoryFile         = "file"
+	MemoryKernel       = "kernel"
+	MemoryShmem        = "shmem"
+	MemoryActiveFile   = "active_file"
+	MemoryInactiveFile = "inactive_file"
+	MemorySwap         = "swap"
+	MemoryPgFault      = "pgfault"
+	MemoryPgMajFault   = "pgmajfault"
+	MemoryMaxUsage     = "max_usage"
+	MemoryFailcnt      = "failcnt"
+	MemoryOOMEvents    = "oom_events"
+)
+
+// memoryStatKeys maps the normalized keys to the keys used in memory.stat,
+// cgroup v2 keys first, then the hierarchical and plain cgroup v1 keys
+var memoryStatKeys = map[string][]string{
+	MemoryAnon:         {"anon", "total_rss", "rss"},
+	MemoryFile:         {"file", "total_cache", "cache"},
+	MemoryKernel:       {"kernel"},
+	MemoryShmem:        {"shmem", "total_shmem"},
+	MemoryActiveFile:   {"active_file", "total_active_file"},
+	MemoryInactiveFile: {"inactive_file", "total_inactive_file"},
+	MemorySwap:         {"total_swap", "swap"},
+	MemoryPgFault:      {"pgfault", "total_pgfault"},
+	MemoryPgMajFault:   {"pgmajfault", "total_pgmajfault"},
+}
+
+type ContainerMemoryStats struct {
+	// usage without inactive file cache (same as docker stats)
+	UsageBytes uint64
+	LimitBytes uint64
+
+	// breakdown by the Memory* keys, only contains values available on the hosts cgroup version
+	Stats map[string]uint64
+}
+
+// normalizeMemoryStats picks the values of memoryStatKeys from a cgroup v1 or v2 memory.stat
+func normalizeMemoryStats(raw map[string]uint64) map[string]uint64 {
+	stats := make(map[string]uint64, len(memoryStatKeys))
+	for key, candidates := range memoryStatKeys {
+		for _, candidate := range candidates {
+			if value, ok := raw[candidate]; ok {
+				stats[key] = value
+				break
+			}
+		}
+	}
+	return stats
+}
+
+// memoryUsage subtracts the inactive file cache from the usage
+func memoryUsage(usage uint64, stats map[string]uint64) uint64 {
+	inactive := stats[MemoryInactiveFile]
+	if inactive > usage {
+		return 0
+	}
+	return usage - inactive
+}
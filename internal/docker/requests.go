@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/containerd/errdefs"
+	"github.com/h3rmt/docker-exporter/internal/log"
+)
+
+// RequestError identifies failed docker requests by operation and reason
+type RequestError struct {
+	// list, inspect or stats
+	Op string
+	// timeout, canceled, not_found or error
+	Reason string
+}
+
+// requestLimiter bounds the number of concurrent docker requests and their duration
+type requestLimiter struct {
+	// nil if the concurrency is unlimited
+	sem     chan struct{}
+	timeout time.Duration
+
+	mu     sync.Mutex
+	errors map[RequestError]uint64
+}
+
+func newRequestLimiter(maxConcurrency int, timeout time.Duration) *requestLimiter {
+	l := &requestLimiter{
+		timeout: timeout,
+		errors:  make(map[RequestError]uint64),
+	}
+	if maxConcurrency > 0 {
+		l.sem = make(chan struct{}, maxConcurrency)
+	}
+	return l
+}
+
+// request runs fn once a request slot is free, with the request timeout applied to its context.
+//
+// Failed requests are counted by op and reason.
+func (c *Client) request(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	l := c.limiter
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+			defer func() { <-l.sem }()
+		case <-ctx.Done():
+			l.count(ctx, op, ctx.Err())
+			return ctx.Err()
+		}
+	}
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	err := fn(ctx)
+	// a stream without a sample yet did not send a request
+	if err != nil && !errors.Is(err, errNoStatsSample) {
+		l.count(ctx, op, err)
+	}
+	return err
+}
+
+// withTimeout applies the request timeout to the loads of a cache, which run on the client context instead of a request
+func withTimeout[T any](timeout time.Duration, load func(ctx context.Context) (T, error)) func(ctx context.Context) (T, error) {
+	if timeout <= 0 {
+		return load
+	}
+	return func(ctx context.Context) (T, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return load(ctx)
+	}
+}
+
+func (l *requestLimiter) count(ctx context.Context, op string, err error) {
+	reason := "error"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		reason = "timeout"
+	case errors.Is(err, context.Canceled):
+		reason = "canceled"
+	case errdefs.IsNotFound(err):
+		reason = "not_found"
+	}
+	log.GetLogger().Log(ctx, log.LevelTrace, "Docker request failed", "op", op, "reason", reason, "error", err)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors[RequestError{Op: op, Reason: reason}]++
+}
+
+// RequestErrors returns the number of failed docker requests since the exporter started
+func (c *Client) RequestErrors() map[RequestError]uint64 {
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+	return copyMap(c.limiter.errors)
+}
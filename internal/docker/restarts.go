@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"sync"
+	"time"
+)
+
+// RestartWindows are the windows restarts are reported for
+var RestartWindows = []time.Duration{5 * time.Minute, time.Hour}
+
+// RestartSummary holds the restarts of a container in the RestartWindows
+type RestartSummary struct {
+	InWindow     map[time.Duration]int
+	Crashlooping bool
+}
+
+type restartHistory struct {
+	restartCount int
+	startedAt    uint64
+	// time of each restart seen since the exporter started, oldest first
+	restarts []time.Time
+}
+
+// restartTracker compares RestartCount and StartedAt of a container across inspects
+type restartTracker struct {
+	mu         sync.Mutex
+	containers map[string]*restartHistory
+
+	// a container is crashlooping if it restarted threshold times in window
+	threshold int
+	window    time.Duration
+	retention time.Duration
+}
+
+func newRestartTracker(threshold int, window time.Duration) *restartTracker {
+	retention := window
+	for _, w := range RestartWindows {
+		retention = max(retention, w)
+	}
+	return &restartTracker{
+		containers: make(map[string]*restartHistory),
+		threshold:  threshold,
+		window:     window,
+		retention:  retention,
+	}
+}
+
+// observe records restarts since the last observation of the container.
+//
+// The first observation only sets the baseline, restarts before the exporter started are not counted.
+func (t *restartTracker) observe(containerID string, restartCount int, startedAt uint64) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.containers[containerID]
+	if !ok {
+		t.containers[containerID] = &restartHistory{restartCount: restartCount, startedAt: startedAt}
+		return
+	}
+
+	// RestartCount only counts restarts by the restart policy, a newer StartedAt also catches manual restarts.
+	// Both signals usually see the same restart, so the larger one is used instead of the sum.
+	restarted := max(restartCount-h.restartCount, 0)
+	if startedAt > h.startedAt && h.startedAt > 0 {
+		restarted = max(restarted, 1)
+	}
+	// all restarts since the last observation are recorded at the last start time
+	at := now
+	if startedAt > 0 {
+		at = time.Unix(int64(startedAt), 0)
+	}
+	for i := 0; i < restarted; i++ {
+		h.restarts = append(h.restarts, at)
+	}
+	h.restartCount = restartCount
+	h.startedAt = startedAt
+
+	// drop restarts outside all windows
+	cutoff := now.Add(-t.retention)
+	i := 0
+	for i < len(h.restarts) && h.restarts[i].Before(cutoff) {
+		i++
+	}
+	h.restarts = h.restarts[i:]
+}
+
+func (t *restartTracker) summary(containerID string) RestartSummary {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	summary := RestartSummary{InWindow: make(map[time.Duration]int, len(RestartWindows))}
+	var restarts []time.Time
+	if h, ok := t.containers[containerID]; ok {
+		restarts = h.restarts
+	}
+	count := func(window time.Duration) int {
+		cutoff := now.Add(-window)
+		n := 0
+		for _, r := range restarts {
+			if !r.Before(cutoff) {
+				n++
+			}
+		}
+		return n
+	}
+	for _, w := range RestartWindows {
+		summary.InWindow[w] = count(w)
+	}
+	summary.Crashlooping = t.threshold > 0 && count(t.window) >= t.threshold
+	return summary
+}
+
+// ContainerRestarts returns the restarts of the container seen by previous inspects
+func (c *Client) ContainerRestarts(containerID string) RestartSummary {
+	return c.restarts.summary(containerID)
+}
@@ -0,0 +1,204 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+)
+
+// SnapshotOptions selects what is collected per container in a snapshot
+type SnapshotOptions struct {
+	// inspect with container sizes
+	Size bool
+	// also get container stats
+	Stats bool
+	// calculate cpu usage in stats
+	Cpu bool
+}
+
+// covers returns if a snapshot taken with o contains everything requested by other
+func (o SnapshotOptions) covers(other SnapshotOptions) bool {
+	return (o.Size || !other.Size) && (o.Stats || !other.Stats) && (o.Cpu || !other.Cpu)
+}
+
+func (o SnapshotOptions) merge(other SnapshotOptions) SnapshotOptions {
+	return SnapshotOptions{
+		Size:  o.Size || other.Size,
+		Stats: o.Stats || other.Stats,
+		Cpu:   o.Cpu || other.Cpu,
+	}
+}
+
+type ContainerResult struct {
+	Inspect    ContainerInspect
+	InspectErr error
+	// empty if stats were not requested
+	Stats    ContainerStats
+	StatsErr error
+}
+
+// ContainerSnapshot is shared between all callers and must not be modified
+type ContainerSnapshot struct {
+	Containers []ContainerInfo
+	Results    map[string]ContainerResult // containerID -> result
+	// time the snapshot was started
+	Time    time.Time
+	Options SnapshotOptions
+	// the caller's context ended before all containers were inspected, Results misses the remaining containers
+	Partial bool
+}
+
+type snapshotState struct {
+	mu     sync.Mutex
+	maxAge time.Duration
+	last   *ContainerSnapshot
+	// snapshot currently being taken, nil if none
+	flight *snapshotFlight
+}
+
+type snapshotFlight struct {
+	options  SnapshotOptions
+	done     chan struct{}
+	snapshot ContainerSnapshot
+	err      error
+
+	// results collected so far, for callers that can't wait for the whole snapshot
+	mu      sync.Mutex
+	partial ContainerSnapshot
+}
+
+// partialSnapshot returns a copy of the results collected so far, false if the containers are not listed yet
+func (f *snapshotFlight) partialSnapshot() (ContainerSnapshot, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.partial.Containers == nil {
+		return ContainerSnapshot{}, false
+	}
+	snapshot := f.partial
+	snapshot.Results = copyMap(f.partial.Results)
+	snapshot.Partial = true
+	return snapshot, true
+}
+
+// ContainerSnapshot lists all containers and inspects them (and gets their stats).
+//
+// Concurrent calls share one snapshot, snapshots younger than the configured max age are reused.
+// If ctx ends before the snapshot is complete, the results collected so far are returned as a partial snapshot.
+func (c *Client) ContainerSnapshot(ctx context.Context, options SnapshotOptions) (ContainerSnapshot, error) {
+	s := c.snapshots
+	s.mu.Lock()
+	if s.last != nil && time.Since(s.last.Time) < s.maxAge && s.last.Options.covers(options) {
+		last := *s.last
+		s.mu.Unlock()
+		log.GetLogger().Log(ctx, log.LevelTrace, "Reusing container snapshot", "age", time.Since(last.Time))
+		return last, nil
+	}
+
+	f := s.flight
+	if f == nil || !f.options.covers(options) {
+		// take everything the other callers needed, so they can reuse this snapshot
+		if f != nil {
+			options = options.merge(f.options)
+		}
+		if s.last != nil {
+			options = options.merge(s.last.Options)
+		}
+		f = &snapshotFlight{options: options, done: make(chan struct{})}
+		s.flight = f
+		c.background.Add(1)
+		go c.runSnapshot(f)
+	} else {
+		log.GetLogger().Log(ctx, log.LevelTrace, "Waiting for running container snapshot")
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.snapshot, f.err
+	case <-ctx.Done():
+		if partial, ok := f.partialSnapshot(); ok {
+			log.GetLogger().DebugContext(ctx, "Returning partial container snapshot", "containers", len(partial.Containers), "results", len(partial.Results))
+			return partial, nil
+		}
+		return ContainerSnapshot{}, ctx.Err()
+	}
+}
+
+func (c *Client) runSnapshot(f *snapshotFlight) {
+	defer c.background.Done()
+	// the snapshot is shared, so it must not be cancelled by the caller that started it
+	f.snapshot, f.err = c.takeSnapshot(c.ctx, f)
+
+	s := c.snapshots
+	s.mu.Lock()
+	if s.flight == f {
+		s.flight = nil
+	}
+	if f.err == nil && (s.last == nil || f.snapshot.Time.After(s.last.Time)) {
+		s.last = &f.snapshot
+	}
+	s.mu.Unlock()
+	close(f.done)
+}
+
+func (c *Client) takeSnapshot(ctx context.Context, f *snapshotFlight) (ContainerSnapshot, error) {
+	options := f.options
+	start := time.Now()
+	var containers []ContainerInfo
+	err := c.request(ctx, "list", func(ctx context.Context) (err error) {
+		containers, err = c.ListAllRunningContainers(ctx)
+		return err
+	})
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	f.mu.Lock()
+	f.partial = ContainerSnapshot{
+		Containers: containers,
+		Results:    make(map[string]ContainerResult, len(containers)),
+		Time:       start,
+		Options:    options,
+	}
+	f.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, container := range containers {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			var result ContainerResult
+			result.InspectErr = c.request(ctx, "inspect", func(ctx context.Context) (err error) {
+				result.Inspect, err = c.InspectContainer(ctx, id, options.Size)
+				return err
+			})
+			if result.InspectErr != nil {
+				log.GetLogger().WarnContext(ctx, "Failed to inspect container", "error", result.InspectErr, "container_id", id)
+			}
+			if options.Stats {
+				result.StatsErr = c.request(ctx, "stats", func(ctx context.Context) (err error) {
+					result.Stats, err = c.GetContainerStats(ctx, id, options.Cpu)
+					return err
+				})
+				if errors.Is(result.StatsErr, errNoStatsSample) {
+					log.GetLogger().DebugContext(ctx, "Skipping container without stats sample", "container_id", id)
+				} else if result.StatsErr != nil {
+					log.GetLogger().WarnContext(ctx, "Failed to get container stats", "error", result.StatsErr, "container_id", id)
+				}
+			}
+			f.mu.Lock()
+			f.partial.Results[id] = result
+			f.mu.Unlock()
+		}(container.ID)
+	}
+	wg.Wait()
+	log.GetLogger().DebugContext(ctx, "Took container snapshot", "time", time.Since(start), "count", len(containers))
+
+	// all results are written, so the map can be shared without copying
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.partial, nil
+}
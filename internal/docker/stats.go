@@ -3,13 +3,18 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/glob"
 	"github.com/h3rmt/docker-exporter/internal/log"
 	"github.com/moby/moby/client"
 )
 
+// errNoStatsSample is returned with the stream backend until the stream of a container sent its first sample
+var errNoStatsSample = errors.New("no stats sample from stream yet")
+
 type ContainerCpuStats struct {
 	// Raw CPU counters (ns).
 	UsageNS          uint64
@@ -20,28 +25,51 @@ type ContainerCpuStats struct {
 	PreSystemUsageNS uint64
 
 	OnlineCpus uint32
+
+	// CFS throttling counters, only available if the container has a cpu limit
+	Periods          uint64
+	ThrottledPeriods uint64
+	ThrottledTimeNS  uint64
 }
 
 type ContainerNetStats struct {
 	SendBytes   uint64
+	SendPackets uint64
 	SendDropped uint64
 	SendErrors  uint64
 	RecvBytes   uint64
+	RecvPackets uint64
 	RecvDropped uint64
 	RecvErrors  uint64
+
+	// MAC address of the interface, only read by the cgroupfs backend and empty for totals
+	MacAddress string
+}
+
+func (n *ContainerNetStats) add(o ContainerNetStats) {
+	n.SendBytes += o.SendBytes
+	n.SendPackets += o.SendPackets
+	n.SendDropped += o.SendDropped
+	n.SendErrors += o.SendErrors
+	n.RecvBytes += o.RecvBytes
+	n.RecvPackets += o.RecvPackets
+	n.RecvDropped += o.RecvDropped
+	n.RecvErrors += o.RecvErrors
 }
 
 type ContainerStats struct {
 	PIds uint64
 
-	Cpu ContainerCpuStats
-	Net ContainerNetStats
-
-	MemoryUsageKiB uint64
-	MemoryLimitKiB uint64
+	Cpu    ContainerCpuStats
+	Net    ContainerNetStats
+	Memory ContainerMemoryStats
+	// network stats per interface name
+	Interfaces map[string]ContainerNetStats
 
 	BlockInputBytes  uint64
 	BlockOutputBytes uint64
+	// block io per device, sorted by device number
+	Blkio []ContainerBlkioDevice
 }
 
 type recStats struct {
@@ -56,6 +84,11 @@ type recStats struct {
 			UsageInUsermode   uint64 `json:"usage_in_usermode"`
 			TotalUsage        uint64 `json:"total_usage"`
 		} `json:"cpu_usage"`
+		ThrottlingData struct {
+			Periods          uint64 `json:"periods"`
+			ThrottledPeriods uint64 `json:"throttled_periods"`
+			ThrottledTime    uint64 `json:"throttled_time"`
+		} `json:"throttling_data"`
 	} `json:"cpu_stats"`
 	PreCpuStats struct {
 		SystemCpuUsage uint64 `json:"system_cpu_usage"`
@@ -67,25 +100,24 @@ type recStats struct {
 		} `json:"cpu_usage"`
 	} `json:"precpu_stats"`
 	BlkioStats struct {
-		IoServiceBytesRecursive []struct {
-			Major int    `json:"major"`
-			Minor int    `json:"minor"`
-			Op    string `json:"op"`
-			Value int    `json:"value"`
-		} `json:"io_service_bytes_recursive"`
+		IoServiceBytesRecursive []blkioEntry `json:"io_service_bytes_recursive"`
+		IoServicedRecursive     []blkioEntry `json:"io_serviced_recursive"`
 	} `json:"blkio_stats"`
 	MemoryStats struct {
 		Usage uint64 `json:"usage"`
 		Limit uint64 `json:"limit"`
-		Stats struct {
-			InactiveFile uint64 `json:"inactive_file"`
-		} `json:"stats"`
+		// cgroup v1 only
+		MaxUsage uint64            `json:"max_usage"`
+		Failcnt  uint64            `json:"failcnt"`
+		Stats    map[string]uint64 `json:"stats"`
 	} `json:"memory_stats"`
 	Networks map[string]struct {
 		RxBytes   uint64 `json:"rx_bytes"`
+		RxPackets uint64 `json:"rx_packets"`
 		RxErrors  uint64 `json:"rx_errors"`
 		RxDropped uint64 `json:"rx_dropped"`
 		TxBytes   uint64 `json:"tx_bytes"`
+		TxPackets uint64 `json:"tx_packets"`
 		TxErrors  uint64 `json:"tx_errors"`
 		TxDropped uint64 `json:"tx_dropped"`
 	} `json:"networks"`
@@ -98,6 +130,9 @@ type cpuEntry struct {
 
 func (c *Client) GetContainerStats(ctx context.Context, containerID string, cpu bool) (ContainerStats, error) {
 	stats, err := c.getContainerStats(ctx, containerID, cpu)
+	if errors.Is(err, errNoStatsSample) {
+		return ContainerStats{}, err
+	}
 	if err != nil {
 		glob.SetError("GetContainerStats", &err)
 		return ContainerStats{}, err
@@ -106,7 +141,51 @@ func (c *Client) GetContainerStats(ctx context.Context, containerID string, cpu
 	return stats, nil
 }
 
+// addPreviousCpuUsage sets the Pre* values to the usage of the last call and stores the current usage
+func (c *Client) addPreviousCpuUsage(containerID string, data *ContainerCpuStats) {
+	c.cpuStatsRWMutex.Lock()
+	defer c.cpuStatsRWMutex.Unlock()
+	prev := c.cpuStatsCache[containerID]
+	data.PreUsageNS = prev.UsageNS
+	data.PreSystemUsageNS = prev.SystemUsageNS
+	c.cpuStatsCache[containerID] = cpuEntry{
+		UsageNS:       data.UsageNS,
+		SystemUsageNS: data.SystemUsageNS,
+	}
+}
+
 func (c *Client) getContainerStats(ctx context.Context, containerID string, cpu bool) (ContainerStats, error) {
+	if c.streams != nil {
+		stats, ok := c.getContainerStatsStream(ctx, containerID, cpu)
+		if !ok {
+			// no sample yet, e.g. the container just started or its stream reconnected. Empty stats would reset the counters
+			// and a blocking api call per container would defeat the stream, so the stats are skipped for this scrape
+			return ContainerStats{}, errNoStatsSample
+		}
+		return stats, nil
+	}
+	if c.cgroup != nil {
+		stats, err := c.getContainerStatsCgroup(ctx, containerID, cpu)
+		if err == nil {
+			return stats, nil
+		}
+		// fall back to the docker api, e.g. for containers in a custom cgroup parent
+		warned := false
+		c.cgroup.fallbackWarning.Do(func() {
+			warned = true
+			log.GetLogger().WarnContext(ctx, "Failed to read container stats from cgroupfs, using docker api (only logged once)", "container_id", containerID, "error", err)
+		})
+		if !warned {
+			log.GetLogger().DebugContext(ctx, "Failed to read container stats from cgroupfs, using docker api", "container_id", containerID, "error", err)
+		}
+	}
+	return c.getContainerStatsAPI(ctx, containerID, cpu)
+}
+
+func (c *Client) getContainerStatsAPI(ctx context.Context, containerID string, cpu bool) (ContainerStats, error) {
+	// includes reading the body, as docker only starts sending it once the sample is ready
+	start := time.Now()
+	defer c.latencies.observe(OpStats, start)
 	stats, err := c.client.ContainerStats(ctx, containerID, client.ContainerStatsOptions{
 		Stream:                false,
 		IncludePreviousSample: false,
@@ -126,84 +205,80 @@ func (c *Client) getContainerStats(ctx context.Context, containerID string, cpu
 		return ContainerStats{}, err
 	}
 
-	var data ContainerCpuStats
+	stat := c.statsFromRec(ctx, containerID, &rec, cpu)
 	if cpu {
-		c.cpuStatsRWMutex.RLock()
-		prev := c.cpuStatsCache[containerID]
-		c.cpuStatsRWMutex.RUnlock()
+		c.addPreviousCpuUsage(containerID, &stat.Cpu)
+	}
+	return stat, nil
+}
 
+// statsFromRec converts a stats api response, the Pre* cpu values are left empty
+func (c *Client) statsFromRec(ctx context.Context, containerID string, rec *recStats, cpu bool) ContainerStats {
+	var data ContainerCpuStats
+	if cpu {
 		data = ContainerCpuStats{
 			UsageNS:          rec.CpuStats.CpuUsage.TotalUsage,
 			UsageUserNS:      rec.CpuStats.CpuUsage.UsageInUsermode,
 			UsageKernelNS:    rec.CpuStats.CpuUsage.UsageInKernelmode,
-			PreUsageNS:       prev.UsageNS,
 			SystemUsageNS:    rec.CpuStats.SystemCpuUsage,
-			PreSystemUsageNS: prev.SystemUsageNS,
 			OnlineCpus:       rec.CpuStats.OnlineCpus,
+			Periods:          rec.CpuStats.ThrottlingData.Periods,
+			ThrottledPeriods: rec.CpuStats.ThrottlingData.ThrottledPeriods,
+			ThrottledTimeNS:  rec.CpuStats.ThrottlingData.ThrottledTime,
 		}
-
-		c.cpuStatsRWMutex.Lock()
-		c.cpuStatsCache[containerID] = cpuEntry{
-			UsageNS:       rec.CpuStats.CpuUsage.TotalUsage,
-			SystemUsageNS: rec.CpuStats.SystemCpuUsage,
-		}
-		c.cpuStatsRWMutex.Unlock()
 	}
 
 	// Network totals
-	var netSendBytes uint64
-	var netSendErrors uint64
-	var netSendDropped uint64
-	var netRecBytes uint64
-	var netRecErrors uint64
-	var netRecDropped uint64
-	for _, net := range rec.Networks {
-		netSendBytes += net.TxBytes
-		netSendErrors += net.TxErrors
-		netSendDropped += net.TxDropped
-		netRecBytes += net.RxBytes
-		netRecErrors += net.RxErrors
-		netRecDropped += net.RxDropped
-	}
-	net := ContainerNetStats{
-		SendBytes:   netSendBytes,
-		SendDropped: netSendDropped,
-		SendErrors:  netSendErrors,
-		RecvBytes:   netRecBytes,
-		RecvDropped: netRecDropped,
-		RecvErrors:  netRecErrors,
+	var net ContainerNetStats
+	interfaces := make(map[string]ContainerNetStats, len(rec.Networks))
+	for name, n := range rec.Networks {
+		iface := ContainerNetStats{
+			SendBytes:   n.TxBytes,
+			SendPackets: n.TxPackets,
+			SendDropped: n.TxDropped,
+			SendErrors:  n.TxErrors,
+			RecvBytes:   n.RxBytes,
+			RecvPackets: n.RxPackets,
+			RecvDropped: n.RxDropped,
+			RecvErrors:  n.RxErrors,
+		}
+		interfaces[name] = iface
+		net.add(iface)
 	}
 
-	// Block IO totals
+	// Block IO per device and totals
+	blkio := parseBlkioStats(ctx, containerID, rec.BlkioStats.IoServiceBytesRecursive, rec.BlkioStats.IoServicedRecursive)
 	var blockInputBytes uint64
 	var blockOutputBytes uint64
-	for _, ioB := range rec.BlkioStats.IoServiceBytesRecursive {
-		switch ioB.Op {
-		case "read":
-			blockInputBytes += uint64(ioB.Value)
-		case "write":
-			blockOutputBytes += uint64(ioB.Value)
-		default:
-			log.GetLogger().WarnContext(
-				ctx,
-				"Unknown blkio operation",
-				"operation",
-				ioB.Op,
-				"container_id",
-				containerID,
-			)
-		}
+	for _, device := range blkio {
+		blockInputBytes += device.ReadBytes
+		blockOutputBytes += device.WriteBytes
+	}
+
+	// Memory breakdown
+	memStats := normalizeMemoryStats(rec.MemoryStats.Stats)
+	if rec.MemoryStats.MaxUsage > 0 {
+		memStats[MemoryMaxUsage] = rec.MemoryStats.MaxUsage
+	}
+	if _, ok := rec.MemoryStats.Stats["cache"]; ok {
+		// failcnt is only reported on cgroup v1, but can be 0
+		memStats[MemoryFailcnt] = rec.MemoryStats.Failcnt
+	}
+	memStats[MemoryOOMEvents] = c.containerOOMEvents(containerID)
+	mem := ContainerMemoryStats{
+		UsageBytes: memoryUsage(rec.MemoryStats.Usage, memStats),
+		LimitBytes: rec.MemoryStats.Limit,
+		Stats:      memStats,
 	}
 
-	stat := ContainerStats{
+	return ContainerStats{
 		PIds:             rec.PidsStats.Current,
 		Cpu:              data,
-		MemoryUsageKiB:   (rec.MemoryStats.Usage - rec.MemoryStats.Stats.InactiveFile) / 1024,
-		MemoryLimitKiB:   rec.MemoryStats.Limit / 1024,
+		Memory:           mem,
 		Net:              net,
+		Interfaces:       interfaces,
 		BlockInputBytes:  blockInputBytes,
 		BlockOutputBytes: blockOutputBytes,
+		Blkio:            blkio,
 	}
-
-	return stat, nil
 }
@@ -0,0 +1,193 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"sync"
+
+	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/events"
+	"github.com/moby/moby/client"
+)
+
+// StatsBackendStream keeps a streaming stats connection open per running container
+const StatsBackendStream = "stream"
+
+type statsStreams struct {
+	mu      sync.Mutex
+	streams map[string]*statsStream // containerID -> stream
+}
+
+type statsStream struct {
+	cancel context.CancelFunc
+
+	mu sync.RWMutex
+	// last two samples received, nil until received
+	latest   *recStats
+	previous *recStats
+}
+
+func newStatsStreams() *statsStreams {
+	return &statsStreams{
+		streams: make(map[string]*statsStream),
+	}
+}
+
+// getContainerStatsStream returns the latest sample of the container, false if there is none yet
+func (c *Client) getContainerStatsStream(ctx context.Context, containerID string, cpu bool) (ContainerStats, bool) {
+	c.streams.mu.Lock()
+	s, ok := c.streams.streams[containerID]
+	c.streams.mu.Unlock()
+	if !ok {
+		return ContainerStats{}, false
+	}
+
+	s.mu.RLock()
+	latest, previous := s.latest, s.previous
+	s.mu.RUnlock()
+	if latest == nil {
+		return ContainerStats{}, false
+	}
+
+	stats := c.statsFromRec(ctx, containerID, latest, cpu)
+	if cpu && previous != nil {
+		stats.Cpu.PreUsageNS = previous.CpuStats.CpuUsage.TotalUsage
+		stats.Cpu.PreSystemUsageNS = previous.CpuStats.SystemCpuUsage
+	} else if cpu {
+		// docker sends the usage of the sample before, so the first sample already has a cpu delta
+		stats.Cpu.PreUsageNS = latest.PreCpuStats.CpuUsage.TotalUsage
+		stats.Cpu.PreSystemUsageNS = latest.PreCpuStats.SystemCpuUsage
+	}
+	return stats, true
+}
+
+// syncStatsStreams starts streams for running containers and stops the ones of containers that are gone
+func (c *Client) syncStatsStreams(ctx context.Context, containers []ContainerInfo) {
+	running := make(map[string]struct{}, len(containers))
+	for _, info := range containers {
+		if info.State == container.StateRunning {
+			running[info.ID] = struct{}{}
+			c.startStatsStream(ctx, info.ID)
+		}
+	}
+
+	c.streams.mu.Lock()
+	defer c.streams.mu.Unlock()
+	for id, s := range c.streams.streams {
+		if _, ok := running[id]; !ok {
+			log.GetLogger().DebugContext(ctx, "Stopping stats stream of removed container", "container_id", id)
+			s.cancel()
+			delete(c.streams.streams, id)
+		}
+	}
+}
+
+// eventAttributes are the attributes of container events that are not labels
+var eventAttributes = []string{"name", "image", "exitCode"}
+
+// containerInfoFromEvent returns the fields of a started container the filter matches on
+func containerInfoFromEvent(msg events.Message) ContainerInfo {
+	// container labels are part of the event attributes, next to name, image and exitCode
+	labels := maps.Clone(msg.Actor.Attributes)
+	for _, attribute := range eventAttributes {
+		delete(labels, attribute)
+	}
+	return ContainerInfo{
+		ID:     msg.Actor.ID,
+		Names:  []string{msg.Actor.Attributes["name"]},
+		Image:  msg.Actor.Attributes["image"],
+		State:  container.StateRunning,
+		Labels: labels,
+	}
+}
+
+// handleStreamEvent starts and stops streams as containers start and stop between scrapes
+func (c *Client) handleStreamEvent(ctx context.Context, msg events.Message) {
+	if msg.Type != events.ContainerEventType {
+		return
+	}
+	switch msg.Action {
+	case events.ActionStart, events.ActionUnPause:
+		if !c.filter.matches(containerInfoFromEvent(msg)) {
+			return
+		}
+		c.startStatsStream(ctx, msg.Actor.ID)
+	case events.ActionDie, events.ActionDestroy:
+		c.stopStatsStream(ctx, msg.Actor.ID)
+	}
+}
+
+func (c *Client) startStatsStream(ctx context.Context, containerID string) {
+	c.streams.mu.Lock()
+	defer c.streams.mu.Unlock()
+	if _, ok := c.streams.streams[containerID]; ok || c.ctx.Err() != nil {
+		return
+	}
+
+	log.GetLogger().DebugContext(ctx, "Starting stats stream", "container_id", containerID)
+	// the stream outlives the request that started it
+	streamCtx, cancel := context.WithCancel(c.ctx)
+	s := &statsStream{cancel: cancel}
+	c.streams.streams[containerID] = s
+	c.background.Add(1)
+	go func() {
+		defer c.background.Done()
+		c.runStatsStream(streamCtx, containerID, s)
+	}()
+}
+
+func (c *Client) stopStatsStream(ctx context.Context, containerID string) {
+	c.streams.mu.Lock()
+	defer c.streams.mu.Unlock()
+	if s, ok := c.streams.streams[containerID]; ok {
+		log.GetLogger().DebugContext(ctx, "Stopping stats stream", "container_id", containerID)
+		s.cancel()
+		delete(c.streams.streams, containerID)
+	}
+}
+
+// runStatsStream reads samples until the stream ends, the stream is restarted by the next syncStatsStreams if the container still runs
+func (c *Client) runStatsStream(ctx context.Context, containerID string, s *statsStream) {
+	defer func() {
+		s.cancel()
+		c.streams.mu.Lock()
+		if c.streams.streams[containerID] == s {
+			delete(c.streams.streams, containerID)
+		}
+		c.streams.mu.Unlock()
+	}()
+
+	stats, err := c.client.ContainerStats(ctx, containerID, client.ContainerStatsOptions{
+		Stream: true,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.GetLogger().WarnContext(ctx, "Failed to open stats stream", "container_id", containerID, "error", err)
+		}
+		return
+	}
+	defer func() {
+		if err := stats.Body.Close(); err != nil {
+			log.GetLogger().ErrorContext(ctx, "Failed to close container stats reader", "error", err)
+		}
+	}()
+
+	decoder := json.NewDecoder(stats.Body)
+	for {
+		var rec recStats
+		if err := decoder.Decode(&rec); err != nil {
+			if ctx.Err() == nil {
+				log.GetLogger().DebugContext(ctx, "Stats stream ended", "container_id", containerID, "error", err)
+			}
+			return
+		}
+		log.GetLogger().Log(ctx, log.LevelTrace, "Received stats sample", "container_id", containerID)
+
+		s.mu.Lock()
+		s.previous = s.latest
+		s.latest = &rec
+		s.mu.Unlock()
+	}
+}
@@ -2,9 +2,9 @@ package exporter
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/docker"
@@ -17,13 +17,28 @@ import (
 
 // CollectorConfig holds which collector groups are enabled
 type CollectorConfig struct {
-	System           bool
+	System bool
+	// docker disk usage, part of the system group unless selected with collect[]=disk
+	Disk             bool
 	Container        bool
 	ContainerNetwork bool
-	ContainerCPU     bool
-	ContainerFS      bool
-	ContainerStats   bool
+	// export network metrics per interface instead of the container total
+	ContainerNetworkPerInterface bool
+	ContainerCPU                 bool
+	ContainerFS                  bool
+	ContainerStats               bool
+	ContainerMemory              bool
+	ContainerBlkio               bool
+	// export the old docker_container_mem_*_kib metrics
+	MemoryKiB        bool
+	ContainerHealth  bool
+	ContainerRestart bool
 	Images           bool
+	Events           bool
+	Compose          bool
+
+	// ContainerLabels are docker labels copied onto docker_container_info
+	ContainerLabels []string
 }
 
 // DockerCollector implements the prometheus.Collector interface
@@ -31,15 +46,65 @@ type DockerCollector struct {
 	dockerClient *docker.Client
 	version      string
 	config       CollectorConfig
+
+	// depends on config.ContainerLabels
+	containerInfoDesc *prometheus.Desc
+	// depends on config.ContainerNetworkPerInterface
+	containerNetDescs containerNetDescs
+
+	// context of the scrape, the prometheus.Collector interface has no way to pass it to Collect
+	ctx context.Context
 }
 
 var (
+	collectorDurationDesc = prometheus.NewDesc(
+		"docker_exporter_collector_duration_seconds",
+		"Duration of a collector scrape",
+		[]string{"hostname", "collector"},
+		nil,
+	)
+	collectorSuccessDesc = prometheus.NewDesc(
+		"docker_exporter_collector_success",
+		"Whether a collector succeeded",
+		[]string{"hostname", "collector"},
+		nil,
+	)
+	dockerAPIDurationDesc = prometheus.NewDesc(
+		"docker_exporter_docker_api_request_duration_seconds",
+		"Latency of docker api requests by operation",
+		[]string{"hostname", "op"},
+		nil,
+	)
+	cacheAgeDesc = prometheus.NewDesc(
+		"docker_exporter_cache_age_seconds",
+		"Time since the last successful refresh of the cache",
+		[]string{"hostname", "cache"},
+		nil,
+	)
+	scrapePartialDesc = prometheus.NewDesc(
+		"docker_exporter_scrape_partial",
+		"1 if the scrape timeout was reached before all containers were collected",
+		[]string{"hostname"},
+		nil,
+	)
 	exporterInfoDesc = prometheus.NewDesc(
 		"docker_exporter_info",
 		"Information about the docker exporter",
 		[]string{"hostname", "version"},
 		nil,
 	)
+	trackedContainersDesc = prometheus.NewDesc(
+		"docker_exporter_tracked_containers",
+		"Number of containers the exporter keeps state (cpu usage, sizes, restarts, ...) for",
+		[]string{"hostname"},
+		nil,
+	)
+	dockerRequestErrorsDesc = prometheus.NewDesc(
+		"docker_exporter_docker_request_errors_total",
+		"Number of failed docker requests by operation and reason (timeout, canceled, not_found, error)",
+		[]string{"hostname", "op", "reason"},
+		nil,
+	)
 	hostOSInfoDesc = prometheus.NewDesc(
 		"docker_exporter_host_os_info",
 		"Information about the host operating system",
@@ -94,12 +159,6 @@ var (
 		[]string{"hostname"},
 		nil,
 	)
-	containerInfoDesc = prometheus.NewDesc(
-		"docker_container_info",
-		"Container information",
-		[]string{"hostname", "container_id", "name", "image_id", "command", "network_mode"},
-		nil,
-	)
 	containerNameDesc = prometheus.NewDesc(
 		"docker_container_name",
 		"Name for the container (can be more than one)",
@@ -148,6 +207,30 @@ var (
 		[]string{"hostname", "container_id"},
 		nil,
 	)
+	containerOOMKilledDesc = prometheus.NewDesc(
+		"docker_container_oom_killed",
+		"1 if the container was last stopped by the OOM killer",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerErrorInfoDesc = prometheus.NewDesc(
+		"docker_container_error_info",
+		"Error of the last container start, only exported if the container has an error",
+		[]string{"hostname", "container_id", "error"},
+		nil,
+	)
+	containerRestartPolicyInfoDesc = prometheus.NewDesc(
+		"docker_container_restart_policy_info",
+		"Restart policy of the container",
+		[]string{"hostname", "container_id", "policy", "max_retry"},
+		nil,
+	)
+	containerAutoRemoveDesc = prometheus.NewDesc(
+		"docker_container_auto_remove",
+		"1 if the container is removed when it exits (--rm)",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
 	containerSizeRootFsDesc = prometheus.NewDesc(
 		"docker_container_rootfs_size_bytes",
 		"Size of rootfs in this container in bytes",
@@ -160,6 +243,42 @@ var (
 		[]string{"hostname", "container_id"},
 		nil,
 	)
+	containerHealthStatusDesc = prometheus.NewDesc(
+		"docker_container_health_status",
+		"Health check status of the container (1 for the current status)",
+		[]string{"hostname", "container_id", "status"},
+		nil,
+	)
+	containerHealthFailingStreakDesc = prometheus.NewDesc(
+		"docker_container_health_failing_streak",
+		"Number of consecutive failed health checks",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerHealthLastProbeDurationDesc = prometheus.NewDesc(
+		"docker_container_health_last_probe_duration_seconds",
+		"Duration of the most recent health check probe in seconds",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerHealthLastProbeExitCodeDesc = prometheus.NewDesc(
+		"docker_container_health_last_probe_exit_code",
+		"Exit code of the most recent health check probe",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerRestartsInWindowDesc = prometheus.NewDesc(
+		"docker_container_restarts_in_window",
+		"Number of restarts seen in the window",
+		[]string{"hostname", "container_id", "window"},
+		nil,
+	)
+	containerCrashloopingDesc = prometheus.NewDesc(
+		"docker_container_crashlooping",
+		"1 if the container restarted more often than the configured threshold",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
 	containerPidsDesc = prometheus.NewDesc(
 		"docker_container_pids",
 		"Number of processes running in the container",
@@ -196,51 +315,75 @@ var (
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerMemLimitKiBDesc = prometheus.NewDesc(
-		"docker_container_mem_limit_kib",
-		"Container memory limit in KiB",
+	containerCpuPeriodsDesc = prometheus.NewDesc(
+		"docker_container_cpu_periods_total",
+		"Number of elapsed CFS enforcement periods",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerMemUsageKiBDesc = prometheus.NewDesc(
-		"docker_container_mem_usage_kib",
-		"Container memory usage in KiB",
+	containerCpuThrottledPeriodsDesc = prometheus.NewDesc(
+		"docker_container_cpu_throttled_periods_total",
+		"Number of CFS periods the container was throttled in",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerNetSendBytesDesc = prometheus.NewDesc(
-		"docker_container_net_send_bytes_total",
-		"Total number of bytes sent",
+	containerCpuThrottledSecondsDesc = prometheus.NewDesc(
+		"docker_container_cpu_throttled_seconds_total",
+		"Total time the container was throttled in seconds",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerNetSendDroppedDesc = prometheus.NewDesc(
-		"docker_container_net_send_dropped_total",
-		"Total number of send packet drop",
+	containerCpuLimitDesc = prometheus.NewDesc(
+		"docker_container_cpu_limit_cores",
+		"Number of CPU cores the container is limited to (--cpus), 0 if unlimited",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerNetSendErrorsDesc = prometheus.NewDesc(
-		"docker_container_net_send_errors_total",
-		"Total number of send errors",
+	containerCpuQuotaDesc = prometheus.NewDesc(
+		"docker_container_cpu_cfs_quota_seconds",
+		"CFS quota of the container in seconds per period (--cpu-quota), 0 if unlimited",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerNetRecvBytesDesc = prometheus.NewDesc(
-		"docker_container_net_receive_bytes_total",
-		"Total number of bytes received",
+	containerCpuPeriodDesc = prometheus.NewDesc(
+		"docker_container_cpu_cfs_period_seconds",
+		"CFS period of the container in seconds (--cpu-period), 0 if default",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerNetRecvDroppedDesc = prometheus.NewDesc(
-		"docker_container_net_receive_dropped_total",
-		"Total number of receive packet drop",
+	containerCpuSharesDesc = prometheus.NewDesc(
+		"docker_container_cpu_shares",
+		"CPU shares of the container (--cpu-shares), 0 if default",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
-	containerNetRecvErrorsDesc = prometheus.NewDesc(
-		"docker_container_net_receive_errors_total",
-		"Total number of receive errors",
+	containerCpusetInfoDesc = prometheus.NewDesc(
+		"docker_container_cpuset_info",
+		"CPUs and memory nodes the container is pinned to, only exported if set",
+		[]string{"hostname", "container_id", "cpus", "mems"},
+		nil,
+	)
+	containerMemLimitKiBDesc = prometheus.NewDesc(
+		"docker_container_mem_limit_kib",
+		"Container memory limit in KiB (deprecated, use docker_container_memory_limit_bytes)",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerMemUsageKiBDesc = prometheus.NewDesc(
+		"docker_container_mem_usage_kib",
+		"Container memory usage in KiB (deprecated, use docker_container_memory_usage_bytes)",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerMemLimitDesc = prometheus.NewDesc(
+		"docker_container_memory_limit_bytes",
+		"Container memory limit in bytes",
+		[]string{"hostname", "container_id"},
+		nil,
+	)
+	containerMemUsageDesc = prometheus.NewDesc(
+		"docker_container_memory_usage_bytes",
+		"Container memory usage without inactive file cache in bytes",
 		[]string{"hostname", "container_id"},
 		nil,
 	)
@@ -256,6 +399,72 @@ var (
 		[]string{"hostname", "container_id"},
 		nil,
 	)
+	containerBlkioReadBytesDesc = prometheus.NewDesc(
+		"docker_container_blkio_read_bytes_total",
+		"Total number of bytes read from the device",
+		[]string{"hostname", "container_id", "device", "major_minor"},
+		nil,
+	)
+	containerBlkioWriteBytesDesc = prometheus.NewDesc(
+		"docker_container_blkio_write_bytes_total",
+		"Total number of bytes written to the device",
+		[]string{"hostname", "container_id", "device", "major_minor"},
+		nil,
+	)
+	containerBlkioReadsDesc = prometheus.NewDesc(
+		"docker_container_blkio_reads_total",
+		"Total number of read operations on the device",
+		[]string{"hostname", "container_id", "device", "major_minor"},
+		nil,
+	)
+	containerBlkioWritesDesc = prometheus.NewDesc(
+		"docker_container_blkio_writes_total",
+		"Total number of write operations on the device",
+		[]string{"hostname", "container_id", "device", "major_minor"},
+		nil,
+	)
+	containerEventsDesc = prometheus.NewDesc(
+		"docker_container_events_total",
+		"Number of container events received from the docker daemon since the exporter started",
+		[]string{"hostname", "action"},
+		nil,
+	)
+	imageEventsDesc = prometheus.NewDesc(
+		"docker_image_events_total",
+		"Number of image events received from the docker daemon since the exporter started",
+		[]string{"hostname", "action"},
+		nil,
+	)
+	composeProjectContainersDesc = prometheus.NewDesc(
+		"docker_compose_project_containers",
+		"Number of containers in the compose project by state",
+		[]string{"hostname", "project", "state"},
+		nil,
+	)
+	composeProjectCpuPercentHostDesc = prometheus.NewDesc(
+		"docker_compose_project_cpu_percent_host",
+		"Percentage of CPU used by all containers of the compose project (relative to host CPU cores)",
+		[]string{"hostname", "project"},
+		nil,
+	)
+	composeProjectMemUsageDesc = prometheus.NewDesc(
+		"docker_compose_project_mem_usage_bytes",
+		"Memory used by all containers of the compose project in bytes",
+		[]string{"hostname", "project"},
+		nil,
+	)
+	composeProjectNetSendBytesDesc = prometheus.NewDesc(
+		"docker_compose_project_net_send_bytes",
+		"Bytes sent by the current containers of the compose project (drops when a container is recreated)",
+		[]string{"hostname", "project"},
+		nil,
+	)
+	composeProjectNetRecvBytesDesc = prometheus.NewDesc(
+		"docker_compose_project_net_receive_bytes",
+		"Bytes received by the current containers of the compose project (drops when a container is recreated)",
+		[]string{"hostname", "project"},
+		nil,
+	)
 	imageCreated = prometheus.NewDesc(
 		"docker_image_created_seconds",
 		"Timestamp in seconds when the image was created",
@@ -276,18 +485,116 @@ var (
 	)
 )
 
+type memStatMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+// containerMemStatDescs maps the keys of docker.ContainerMemoryStats.Stats to metrics
+var containerMemStatDescs = map[string]memStatMetric{
+	docker.MemoryAnon:         newMemStatMetric("docker_container_memory_anon_bytes", "Anonymous memory (heap, stack) of the container in bytes", prometheus.GaugeValue),
+	docker.MemoryFile:         newMemStatMetric("docker_container_memory_file_bytes", "File backed memory (page cache) of the container in bytes", prometheus.GaugeValue),
+	docker.MemoryKernel:       newMemStatMetric("docker_container_memory_kernel_bytes", "Kernel memory of the container in bytes (cgroup v2 only)", prometheus.GaugeValue),
+	docker.MemoryShmem:        newMemStatMetric("docker_container_memory_shmem_bytes", "Shared memory of the container in bytes", prometheus.GaugeValue),
+	docker.MemoryActiveFile:   newMemStatMetric("docker_container_memory_active_file_bytes", "Active file backed memory of the container in bytes", prometheus.GaugeValue),
+	docker.MemoryInactiveFile: newMemStatMetric("docker_container_memory_inactive_file_bytes", "Inactive file backed memory of the container in bytes", prometheus.GaugeValue),
+	docker.MemorySwap:         newMemStatMetric("docker_container_memory_swap_bytes", "Swap used by the container in bytes (cgroup v1 only)", prometheus.GaugeValue),
+	docker.MemoryPgFault:      newMemStatMetric("docker_container_memory_page_faults_total", "Total number of page faults", prometheus.CounterValue),
+	docker.MemoryPgMajFault:   newMemStatMetric("docker_container_memory_major_page_faults_total", "Total number of major page faults", prometheus.CounterValue),
+	docker.MemoryMaxUsage:     newMemStatMetric("docker_container_memory_max_usage_bytes", "Maximum memory usage of the container in bytes (cgroup v1 only)", prometheus.GaugeValue),
+	docker.MemoryFailcnt:      newMemStatMetric("docker_container_memory_failures_total", "Number of times the memory limit was hit (cgroup v1 only)", prometheus.CounterValue),
+	docker.MemoryOOMEvents:    newMemStatMetric("docker_container_memory_oom_events_total", "Number of oom events of the container since the exporter started", prometheus.CounterValue),
+}
+
+func newMemStatMetric(name string, help string, valueType prometheus.ValueType) memStatMetric {
+	return memStatMetric{
+		desc:      prometheus.NewDesc(name, help, []string{"hostname", "container_id"}, nil),
+		valueType: valueType,
+	}
+}
+
 func NewDockerCollector(client *docker.Client, version string, config CollectorConfig) *DockerCollector {
+	config.ContainerLabels = uniqueContainerLabels(config.ContainerLabels)
 	return &DockerCollector{
-		dockerClient: client,
-		version:      version,
-		config:       config,
+		dockerClient:      client,
+		version:           version,
+		config:            config,
+		containerInfoDesc: newContainerInfoDesc(config.ContainerLabels),
+		containerNetDescs: newContainerNetDescs(config.ContainerNetworkPerInterface),
+	}
+}
+
+// WithContext returns a copy of the collector that collects with ctx, e.g. to apply the scrape timeout
+func (c *DockerCollector) WithContext(ctx context.Context) *DockerCollector {
+	cp := *c
+	cp.ctx = ctx
+	return &cp
+}
+
+func newContainerInfoDesc(labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		"docker_container_info",
+		"Container information",
+		append([]string{"hostname", "container_id", "name", "image_id", "command", "network_mode", "compose_project", "compose_service", "compose_container_number"}, containerLabelNames(labels)...),
+		nil,
+	)
+}
+
+type containerNetDescs struct {
+	sendBytes   *prometheus.Desc
+	sendPackets *prometheus.Desc
+	sendDropped *prometheus.Desc
+	sendErrors  *prometheus.Desc
+	recvBytes   *prometheus.Desc
+	recvPackets *prometheus.Desc
+	recvDropped *prometheus.Desc
+	recvErrors  *prometheus.Desc
+}
+
+func newContainerNetDescs(perInterface bool) containerNetDescs {
+	labels := []string{"hostname", "container_id"}
+	if perInterface {
+		labels = append(labels, "interface", "network")
+	}
+	newDesc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, labels, nil)
+	}
+	return containerNetDescs{
+		sendBytes:   newDesc("docker_container_net_send_bytes_total", "Total number of bytes sent"),
+		sendPackets: newDesc("docker_container_net_send_packets_total", "Total number of packets sent"),
+		sendDropped: newDesc("docker_container_net_send_dropped_total", "Total number of send packet drop"),
+		sendErrors:  newDesc("docker_container_net_send_errors_total", "Total number of send errors"),
+		recvBytes:   newDesc("docker_container_net_receive_bytes_total", "Total number of bytes received"),
+		recvPackets: newDesc("docker_container_net_receive_packets_total", "Total number of packets received"),
+		recvDropped: newDesc("docker_container_net_receive_dropped_total", "Total number of receive packet drop"),
+		recvErrors:  newDesc("docker_container_net_receive_errors_total", "Total number of receive errors"),
+	}
+}
+
+func (d containerNetDescs) all() []*prometheus.Desc {
+	return []*prometheus.Desc{
+		d.sendBytes, d.sendPackets, d.sendDropped, d.sendErrors,
+		d.recvBytes, d.recvPackets, d.recvDropped, d.recvErrors,
 	}
 }
 
 func (c *DockerCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		scrapePartialDesc, collectorDurationDesc, collectorSuccessDesc, dockerAPIDurationDesc, cacheAgeDesc,
+	} {
+		ch <- desc
+	}
+
 	if c.config.System {
 		for _, desc := range []*prometheus.Desc{
-			exporterInfoDesc, hostOSInfoDesc,
+			exporterInfoDesc, trackedContainersDesc, dockerRequestErrorsDesc, hostOSInfoDesc,
+		} {
+			ch <- desc
+		}
+	}
+
+	if c.config.Disk {
+		for _, desc := range []*prometheus.Desc{
 			dockerDiskUsageContainersTotalSize, dockerDiskUsageContainersReclaimable,
 			dockerDiskUsageImagesTotalSize, dockerDiskUsageImagesReclaimable,
 			dockerDiskUsageBuildCacheTotalSize, dockerDiskUsageBuildCacheReclaimable,
@@ -299,18 +606,35 @@ func (c *DockerCollector) Describe(ch chan<- *prometheus.Desc) {
 
 	if c.config.Container {
 		for _, desc := range []*prometheus.Desc{
-			containerInfoDesc, containerNameDesc, containerStateDesc, containerCreatedDesc,
+			c.containerInfoDesc, containerNameDesc, containerStateDesc, containerCreatedDesc,
 			containerPortsDesc, containerStartedDesc, containerFinishedAtDesc,
 			containerRestartCountDesc, containerExitCodeDesc,
+			containerOOMKilledDesc, containerErrorInfoDesc, containerRestartPolicyInfoDesc, containerAutoRemoveDesc,
+		} {
+			ch <- desc
+		}
+	}
+
+	if c.config.ContainerHealth {
+		for _, desc := range []*prometheus.Desc{
+			containerHealthStatusDesc, containerHealthFailingStreakDesc,
+			containerHealthLastProbeDurationDesc, containerHealthLastProbeExitCodeDesc,
 		} {
 			ch <- desc
 		}
 	}
 
+	if c.config.ContainerRestart {
+		ch <- containerRestartsInWindowDesc
+		ch <- containerCrashloopingDesc
+	}
+
 	if c.config.ContainerCPU {
 		for _, desc := range []*prometheus.Desc{
 			containerCpuUserNSDesc, containerCpuKernelNSDesc, containerCpuNSDesc,
 			containerCpuPercent, containerCpuPercentHost,
+			containerCpuPeriodsDesc, containerCpuThrottledPeriodsDesc, containerCpuThrottledSecondsDesc,
+			containerCpuLimitDesc, containerCpuQuotaDesc, containerCpuPeriodDesc, containerCpuSharesDesc, containerCpusetInfoDesc,
 		} {
 			ch <- desc
 		}
@@ -319,26 +643,56 @@ func (c *DockerCollector) Describe(ch chan<- *prometheus.Desc) {
 	if c.config.ContainerStats {
 		for _, desc := range []*prometheus.Desc{
 			containerPidsDesc,
-			containerMemLimitKiBDesc, containerMemUsageKiBDesc,
+			containerMemLimitDesc, containerMemUsageDesc,
 			containerBlockInputBytesDesc, containerBlockOutputBytesDesc,
 		} {
 			ch <- desc
 		}
+		if c.config.MemoryKiB {
+			ch <- containerMemLimitKiBDesc
+			ch <- containerMemUsageKiBDesc
+		}
 	}
 
-	if c.config.ContainerNetwork {
+	if c.config.ContainerMemory {
+		for _, metric := range containerMemStatDescs {
+			ch <- metric.desc
+		}
+	}
+
+	if c.config.ContainerBlkio {
 		for _, desc := range []*prometheus.Desc{
-			containerNetSendBytesDesc, containerNetSendDroppedDesc, containerNetSendErrorsDesc,
-			containerNetRecvBytesDesc, containerNetRecvDroppedDesc, containerNetRecvErrorsDesc,
+			containerBlkioReadBytesDesc, containerBlkioWriteBytesDesc,
+			containerBlkioReadsDesc, containerBlkioWritesDesc,
 		} {
 			ch <- desc
 		}
 	}
 
+	if c.config.ContainerNetwork {
+		for _, desc := range c.containerNetDescs.all() {
+			ch <- desc
+		}
+	}
+
 	if c.config.ContainerFS {
 		ch <- containerSizeRootFsDesc
 		ch <- containerSizeRwDesc
 	}
+
+	if c.config.Events {
+		ch <- containerEventsDesc
+		ch <- imageEventsDesc
+	}
+
+	if c.config.Compose {
+		for _, desc := range []*prometheus.Desc{
+			composeProjectContainersDesc, composeProjectCpuPercentHostDesc, composeProjectMemUsageDesc,
+			composeProjectNetSendBytesDesc, composeProjectNetRecvBytesDesc,
+		} {
+			ch <- desc
+		}
+	}
 }
 
 type containerResult struct {
@@ -348,106 +702,161 @@ type containerResult struct {
 }
 
 func (c *DockerCollector) Collect(ch chan<- prometheus.Metric) {
-	ctx := context.Background()
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	start := time.Now()
 
 	hostname := getHostname(ctx)
 
+	// collect runs a collector and reports its duration and success
+	collect := func(name string, fn func() error) {
+		collectorStart := time.Now()
+		err := fn()
+		duration := time.Since(collectorStart)
+		if err != nil {
+			log.GetLogger().ErrorContext(ctx, "Collector failed", "collector", name, "error", err, "time", duration)
+		} else {
+			log.GetLogger().DebugContext(ctx, "Finished collecting metrics", "collector", name, "time", duration)
+		}
+		formatCollectorDuration(ch, hostname, name, duration, err == nil)
+	}
+
 	if c.config.System {
-		c.collectSystem(ctx, ch, hostname)
-		log.GetLogger().DebugContext(ctx, "Finished collecting system metrics", "time", time.Since(start))
+		collect("system", func() error {
+			c.collectSystem(ctx, ch, hostname)
+			return nil
+		})
+	}
+
+	if c.config.Disk {
+		collect("disk", func() error {
+			return c.collectDisk(ctx, ch, hostname)
+		})
 	}
 
+	partial := false
 	if c.config.Container {
-		c.collectContainers(ctx, ch, hostname, start)
-		log.GetLogger().DebugContext(ctx, "Finished collecting container metrics", "time", time.Since(start))
+		collect("container", func() (err error) {
+			partial, err = c.collectContainers(ctx, ch, hostname, start)
+			return err
+		})
 	}
 
 	if c.config.Images {
-		c.collectImages(ctx, ch, hostname)
-		log.GetLogger().DebugContext(ctx, "Finished collecting images metrics", "time", time.Since(start))
+		collect("images", func() error {
+			return c.collectImages(ctx, ch, hostname)
+		})
 	}
 
-	log.GetLogger().DebugContext(ctx, "Finished collecting metrics", "time", time.Since(start))
+	if c.config.Events {
+		c.collectEvents(ch, hostname)
+		log.GetLogger().DebugContext(ctx, "Finished collecting events metrics", "time", time.Since(start))
+	}
+
+	formatDockerAPIDurations(ch, hostname, c.dockerClient.APILatencies())
+	formatCacheAges(ch, hostname, c.dockerClient.CacheAges())
+	formatScrapePartial(ch, hostname, partial)
+	log.GetLogger().DebugContext(ctx, "Finished collecting metrics", "time", time.Since(start), "partial", partial)
 }
 
 func (c *DockerCollector) collectSystem(ctx context.Context, ch chan<- prometheus.Metric, hostname string) {
 	formatSystemInfo(ch, hostname, c.version)
+	formatSystemTrackedContainers(ch, hostname, c.dockerClient.TrackedContainers())
+	formatSystemRequestErrors(ch, hostname, c.dockerClient.RequestErrors())
 	osInfo := osinfo.GetOSInfo(ctx)
 	formatSystemHostInfo(ch, hostname, osInfo)
+}
+
+// collectDisk exports the cached disk usage, it fails if the last refresh of the cache failed
+func (c *DockerCollector) collectDisk(ctx context.Context, ch chan<- prometheus.Metric, hostname string) error {
 	disk := c.dockerClient.Disk(ctx)
 	formatSystemDiskInfo(ch, hostname, disk)
+	return c.dockerClient.DiskUsageError()
 }
 
-func (c *DockerCollector) collectImages(ctx context.Context, ch chan<- prometheus.Metric, hostname string) {
+func (c *DockerCollector) collectImages(ctx context.Context, ch chan<- prometheus.Metric, hostname string) error {
 	images, err := c.dockerClient.ListAllImages(ctx)
 	if err != nil {
-		log.GetLogger().ErrorContext(ctx, "Failed to list images", "error", err)
-		return
+		return fmt.Errorf("list images: %w", err)
 	}
 	for _, image := range images {
 		formatImageInfoCreated(ch, hostname, image)
 		formatImageInfoContainers(ch, hostname, image)
 		formatImageInfoSize(ch, hostname, image)
 	}
+	return nil
+}
+
+func (c *DockerCollector) collectEvents(ch chan<- prometheus.Metric, hostname string) {
+	counts := c.dockerClient.EventCounts()
+	formatContainerEvents(ch, hostname, counts)
+	formatImageEvents(ch, hostname, counts)
 }
 
-func (c *DockerCollector) collectContainers(ctx context.Context, ch chan<- prometheus.Metric, hostname string, start time.Time) {
-	containerInfo, err := c.dockerClient.ListAllRunningContainers(ctx)
+// collectContainers returns true if the snapshot was incomplete because ctx ended
+func (c *DockerCollector) collectContainers(ctx context.Context, ch chan<- prometheus.Metric, hostname string, start time.Time) (bool, error) {
+	needStat := c.config.ContainerStats || c.config.ContainerNetwork || c.config.ContainerCPU || c.config.ContainerMemory || c.config.ContainerBlkio || c.config.Compose
+	needCpu := c.config.ContainerCPU || c.config.Compose
+
+	snapshot, err := c.dockerClient.ContainerSnapshot(ctx, docker.SnapshotOptions{
+		Size:  c.config.ContainerFS,
+		Stats: needStat,
+		Cpu:   needCpu,
+	})
 	if err != nil {
-		log.GetLogger().ErrorContext(ctx, "Failed to list running containers", "error", err)
-		return
+		return ctx.Err() != nil, fmt.Errorf("list running containers: %w", err)
+	}
+	containerInfo := snapshot.Containers
+	if snapshot.Partial {
+		var skipped []string
+		for _, container := range containerInfo {
+			if _, ok := snapshot.Results[container.ID]; !ok {
+				skipped = append(skipped, container.ID)
+			}
+		}
+		log.GetLogger().DebugContext(ctx, "Scrape timeout reached, skipping containers", "skipped", skipped, "count", len(skipped))
 	}
-	log.GetLogger().DebugContext(ctx, "Found running containers", "time", time.Since(start), "count", len(containerInfo))
+	log.GetLogger().DebugContext(ctx, "Got container snapshot", "time", time.Since(start), "age", time.Since(snapshot.Time), "count", len(containerInfo))
 
-	formatContainerInfo(ch, hostname, containerInfo)
+	formatContainerInfo(ch, c.containerInfoDesc, hostname, containerInfo, c.config.ContainerLabels)
 	formatContainerNames(ch, hostname, containerInfo)
 	formatContainerState(ch, hostname, containerInfo)
 	formatContainerCreated(ch, hostname, containerInfo)
 	formatContainerPorts(ch, hostname, containerInfo)
 
-	needStat := c.config.ContainerStats || c.config.ContainerNetwork || c.config.ContainerCPU
-
-	resultCh := make(chan containerResult, len(containerInfo))
-	var wg sync.WaitGroup
-
+	stats := make(map[string]docker.ContainerStats, len(containerInfo))
 	for _, container := range containerInfo {
-		wg.Add(1)
-		go func(container docker.ContainerInfo) {
-			defer wg.Done()
-			id := container.ID
-
-			inspect, err := c.dockerClient.InspectContainer(ctx, id, c.config.ContainerFS)
-			if err != nil {
-				log.GetLogger().WarnContext(ctx, "Failed to inspect container", "error", err, "container_id", id)
-				return
-			}
-
-			var stat docker.ContainerStats
-			if needStat {
-				stat, err = c.dockerClient.GetContainerStats(ctx, id, c.config.ContainerCPU)
-				if err != nil {
-					log.GetLogger().WarnContext(ctx, "Failed to get container stats", "error", err, "container_id", id)
-					return
-				}
-			}
-
-			resultCh <- containerResult{id: id, stat: stat, inspect: inspect}
-		}(container)
-	}
-	log.GetLogger().DebugContext(ctx, "Waiting for container stats", "time", time.Since(start), "count", len(containerInfo))
-
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
+		r, ok := snapshot.Results[container.ID]
+		// containers that failed to inspect or get stats are skipped, the error is logged by the snapshot
+		if !ok || r.InspectErr != nil || (needStat && r.StatsErr != nil) {
+			continue
+		}
+		result := containerResult{id: container.ID, inspect: r.Inspect, stat: r.Stats}
+		stats[result.id] = result.stat
 
-	for result := range resultCh {
 		if c.config.Container {
 			formatContainerStarted(ch, hostname, result.id, result.inspect)
 			formatContainerFinished(ch, hostname, result.id, result.inspect)
 			formatContainerExitCode(ch, hostname, result.id, result.inspect)
 			formatContainerRestartCount(ch, hostname, result.id, result.inspect)
+			formatContainerOOMKilled(ch, hostname, result.id, result.inspect)
+			formatContainerErrorInfo(ch, hostname, result.id, result.inspect)
+			formatContainerRestartPolicyInfo(ch, hostname, result.id, result.inspect)
+			formatContainerAutoRemove(ch, hostname, result.id, result.inspect)
+		}
+
+		if c.config.ContainerHealth {
+			formatContainerHealthStatus(ch, hostname, result.id, result.inspect)
+			formatContainerHealthFailingStreak(ch, hostname, result.id, result.inspect)
+			formatContainerHealthLastProbe(ch, hostname, result.id, result.inspect)
+		}
+
+		if c.config.ContainerRestart {
+			restarts := c.dockerClient.ContainerRestarts(result.id)
+			formatContainerRestartsInWindow(ch, hostname, result.id, restarts)
+			formatContainerCrashlooping(ch, hostname, result.id, restarts)
 		}
 
 		if c.config.ContainerFS {
@@ -461,34 +870,49 @@ func (c *DockerCollector) collectContainers(ctx context.Context, ch chan<- prome
 			formatContainerCpuKernelMicroSeconds(ch, hostname, result.id, result.stat)
 			formatContainerCpuPercentHost(ch, hostname, result.id, result.stat)
 			formatContainerCpuPercent(ch, hostname, result.id, result.stat, result.inspect)
+			formatContainerCpuThrottling(ch, hostname, result.id, result.stat)
+			formatContainerCpuLimits(ch, hostname, result.id, result.inspect)
 		}
 
 		if c.config.ContainerStats {
 			formatContainerPids(ch, hostname, result.id, result.stat)
-			formatContainerMemLimitKiB(ch, hostname, result.id, result.stat)
-			formatContainerMemUsageKiB(ch, hostname, result.id, result.stat)
+			formatContainerMemLimit(ch, hostname, result.id, result.stat)
+			formatContainerMemUsage(ch, hostname, result.id, result.stat)
 			formatBlockOutputBytes(ch, hostname, result.id, result.stat)
 			formatBlockInputBytes(ch, hostname, result.id, result.stat)
+			if c.config.MemoryKiB {
+				formatContainerMemLimitKiB(ch, hostname, result.id, result.stat)
+				formatContainerMemUsageKiB(ch, hostname, result.id, result.stat)
+			}
+		}
+
+		if c.config.ContainerMemory {
+			formatContainerMemStats(ch, hostname, result.id, result.stat)
+		}
+
+		if c.config.ContainerBlkio {
+			formatContainerBlkio(ch, hostname, result.id, result.stat)
 		}
 
 		if c.config.ContainerNetwork {
-			formatContainerNetSendBytes(ch, hostname, result.id, result.stat)
-			formatContainerNetSendDropped(ch, hostname, result.id, result.stat)
-			formatContainerNetSendErrors(ch, hostname, result.id, result.stat)
-			formatContainerNetRecvBytes(ch, hostname, result.id, result.stat)
-			formatContainerNetRecvDropped(ch, hostname, result.id, result.stat)
-			formatContainerNetRecvErrors(ch, hostname, result.id, result.stat)
+			if c.config.ContainerNetworkPerInterface {
+				formatContainerNetInterfaces(ch, c.containerNetDescs, hostname, result.id, result.stat, result.inspect)
+			} else {
+				formatContainerNet(ch, c.containerNetDescs, hostname, result.id, result.stat.Net)
+			}
 		}
 	}
-}
 
-func RegisterCollectorsWithRegistry(cli *docker.Client, reg *prometheus.Registry, version string, config CollectorConfig) {
-	collector := NewDockerCollector(cli, version, config)
-	if reg == nil {
-		prometheus.MustRegister(collector)
-	} else {
-		reg.MustRegister(collector)
+	if c.config.Compose {
+		for _, project := range docker.SummarizeProjects(containerInfo, stats) {
+			formatComposeProjectContainers(ch, hostname, project)
+			formatComposeProjectCpuPercentHost(ch, hostname, project)
+			formatComposeProjectMemUsage(ch, hostname, project)
+			formatComposeProjectNetSendBytes(ch, hostname, project)
+			formatComposeProjectNetRecvBytes(ch, hostname, project)
+		}
 	}
+	return snapshot.Partial, nil
 }
 
 func getHostname(ctx context.Context) string {
@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// collectorGroups maps the names accepted by the collect[] query parameter to the CollectorConfig fields they enable,
+// the names match the --collector.* flags
+var collectorGroups = map[string]func(config *CollectorConfig) *bool{
+	"system":             func(config *CollectorConfig) *bool { return &config.System },
+	"disk":               func(config *CollectorConfig) *bool { return &config.Disk },
+	"container":          func(config *CollectorConfig) *bool { return &config.Container },
+	"container.net":      func(config *CollectorConfig) *bool { return &config.ContainerNetwork },
+	"container.cpu":      func(config *CollectorConfig) *bool { return &config.ContainerCPU },
+	"container.fs":       func(config *CollectorConfig) *bool { return &config.ContainerFS },
+	"container.stats":    func(config *CollectorConfig) *bool { return &config.ContainerStats },
+	"container.memory":   func(config *CollectorConfig) *bool { return &config.ContainerMemory },
+	"container.blkio":    func(config *CollectorConfig) *bool { return &config.ContainerBlkio },
+	"container.health":   func(config *CollectorConfig) *bool { return &config.ContainerHealth },
+	"container.restarts": func(config *CollectorConfig) *bool { return &config.ContainerRestart },
+	"compose":            func(config *CollectorConfig) *bool { return &config.Compose },
+	"images":             func(config *CollectorConfig) *bool { return &config.Images },
+	"events":             func(config *CollectorConfig) *bool { return &config.Events },
+}
+
+// CollectorGroups returns the sorted names of all collector groups
+func CollectorGroups() []string {
+	names := make([]string, 0, len(collectorGroups))
+	for name := range collectorGroups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Filter returns a copy of the config with only the named groups enabled.
+//
+// Fails if a group is unknown or disabled in the config.
+// Selecting a container.* group or compose also enables the container group, like the flags do.
+func (c CollectorConfig) Filter(names []string) (CollectorConfig, error) {
+	filtered := c
+	for _, field := range collectorGroups {
+		*field(&filtered) = false
+	}
+	for _, name := range names {
+		field, ok := collectorGroups[name]
+		if !ok {
+			return CollectorConfig{}, fmt.Errorf("unknown collector %q (want one of %s)", name, strings.Join(CollectorGroups(), ", "))
+		}
+		if !*field(&c) {
+			return CollectorConfig{}, fmt.Errorf("collector %q is disabled", name)
+		}
+		*field(&filtered) = true
+	}
+	filtered.Container = filtered.Container || filtered.ContainerNetwork || filtered.ContainerCPU || filtered.ContainerFS ||
+		filtered.ContainerStats || filtered.ContainerMemory || filtered.ContainerBlkio || filtered.ContainerHealth ||
+		filtered.ContainerRestart || filtered.Compose
+	return filtered, nil
+}
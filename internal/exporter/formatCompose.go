@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"github.com/h3rmt/docker-exporter/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func formatComposeProjectContainers(ch chan<- prometheus.Metric, hostname string, project docker.ProjectSummary) {
+	for state, count := range project.Containers {
+		ch <- prometheus.MustNewConstMetric(
+			composeProjectContainersDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			hostname,
+			project.Name,
+			string(state),
+		)
+	}
+}
+
+func formatComposeProjectCpuPercentHost(ch chan<- prometheus.Metric, hostname string, project docker.ProjectSummary) {
+	ch <- prometheus.MustNewConstMetric(
+		composeProjectCpuPercentHostDesc,
+		prometheus.GaugeValue,
+		project.CpuPercentHost,
+		hostname,
+		project.Name,
+	)
+}
+
+func formatComposeProjectMemUsage(ch chan<- prometheus.Metric, hostname string, project docker.ProjectSummary) {
+	ch <- prometheus.MustNewConstMetric(
+		composeProjectMemUsageDesc,
+		prometheus.GaugeValue,
+		float64(project.MemoryUsageBytes),
+		hostname,
+		project.Name,
+	)
+}
+
+func formatComposeProjectNetSendBytes(ch chan<- prometheus.Metric, hostname string, project docker.ProjectSummary) {
+	ch <- prometheus.MustNewConstMetric(
+		composeProjectNetSendBytesDesc,
+		prometheus.GaugeValue,
+		float64(project.NetSendBytes),
+		hostname,
+		project.Name,
+	)
+}
+
+func formatComposeProjectNetRecvBytes(ch chan<- prometheus.Metric, hostname string, project docker.ProjectSummary) {
+	ch <- prometheus.MustNewConstMetric(
+		composeProjectNetRecvBytesDesc,
+		prometheus.GaugeValue,
+		float64(project.NetRecvBytes),
+		hostname,
+		project.Name,
+	)
+}
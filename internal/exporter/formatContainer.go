@@ -9,18 +9,17 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func formatContainerInfo(ch chan<- prometheus.Metric, hostname string, containerInfo []docker.ContainerInfo) {
+func formatContainerInfo(ch chan<- prometheus.Metric, desc *prometheus.Desc, hostname string, containerInfo []docker.ContainerInfo, labels []string) {
 	for _, c := range containerInfo {
+		values := []string{hostname, c.ID, c.Names[0], c.ImageID, c.Command, c.NetworkMode, c.ComposeProject, c.ComposeService, c.ComposeContainerNumber}
+		for _, label := range labels {
+			values = append(values, c.Labels[label])
+		}
 		ch <- prometheus.MustNewConstMetric(
-			containerInfoDesc,
+			desc,
 			prometheus.GaugeValue,
 			1,
-			hostname,
-			c.ID,
-			c.Names[0],
-			c.ImageID,
-			c.Command,
-			c.NetworkMode,
+			values...,
 		)
 	}
 }
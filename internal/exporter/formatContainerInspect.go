@@ -1,7 +1,10 @@
 package exporter
 
 import (
+	"strconv"
+
 	"github.com/h3rmt/docker-exporter/internal/docker"
+	"github.com/moby/moby/api/types/container"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -64,3 +67,145 @@ func formatContainerExitCode(ch chan<- prometheus.Metric, hostname string, conta
 		containerID,
 	)
 }
+
+func formatContainerOOMKilled(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	ch <- prometheus.MustNewConstMetric(
+		containerOOMKilledDesc,
+		prometheus.GaugeValue,
+		boolToFloat(inspect.OOMKilled),
+		hostname,
+		containerID,
+	)
+}
+
+func formatContainerErrorInfo(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	if inspect.Error == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		containerErrorInfoDesc,
+		prometheus.GaugeValue,
+		1,
+		hostname,
+		containerID,
+		inspect.Error,
+	)
+}
+
+func formatContainerRestartPolicyInfo(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	policy := inspect.RestartPolicyName
+	if policy == "" {
+		policy = "no"
+	}
+	ch <- prometheus.MustNewConstMetric(
+		containerRestartPolicyInfoDesc,
+		prometheus.GaugeValue,
+		1,
+		hostname,
+		containerID,
+		policy,
+		strconv.Itoa(inspect.RestartPolicyMaxRetry),
+	)
+}
+
+func formatContainerAutoRemove(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	ch <- prometheus.MustNewConstMetric(
+		containerAutoRemoveDesc,
+		prometheus.GaugeValue,
+		boolToFloat(inspect.AutoRemove),
+		hostname,
+		containerID,
+	)
+}
+
+func formatContainerCpuLimits(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	ch <- prometheus.MustNewConstMetric(
+		containerCpuLimitDesc,
+		prometheus.GaugeValue,
+		float64(inspect.NanoCpus)/1000000000.0,
+		hostname,
+		containerID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		containerCpuQuotaDesc,
+		prometheus.GaugeValue,
+		float64(max(inspect.CPUQuota, 0))/1000000.0,
+		hostname,
+		containerID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		containerCpuPeriodDesc,
+		prometheus.GaugeValue,
+		float64(inspect.CPUPeriod)/1000000.0,
+		hostname,
+		containerID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		containerCpuSharesDesc,
+		prometheus.GaugeValue,
+		float64(inspect.CPUShares),
+		hostname,
+		containerID,
+	)
+	if inspect.CpusetCpus != "" || inspect.CpusetMems != "" {
+		ch <- prometheus.MustNewConstMetric(
+			containerCpusetInfoDesc,
+			prometheus.GaugeValue,
+			1,
+			hostname,
+			containerID,
+			inspect.CpusetCpus,
+			inspect.CpusetMems,
+		)
+	}
+}
+
+func formatContainerHealthStatus(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	for _, status := range []container.HealthStatus{container.Starting, container.Healthy, container.Unhealthy, container.NoHealthcheck} {
+		ch <- prometheus.MustNewConstMetric(
+			containerHealthStatusDesc,
+			prometheus.GaugeValue,
+			boolToFloat(inspect.HealthStatus == status),
+			hostname,
+			containerID,
+			string(status),
+		)
+	}
+}
+
+func formatContainerHealthFailingStreak(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	ch <- prometheus.MustNewConstMetric(
+		containerHealthFailingStreakDesc,
+		prometheus.GaugeValue,
+		float64(inspect.HealthFailingStreak),
+		hostname,
+		containerID,
+	)
+}
+
+func formatContainerHealthLastProbe(ch chan<- prometheus.Metric, hostname string, containerID string, inspect docker.ContainerInspect) {
+	if inspect.HealthLastProbe == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(
+		containerHealthLastProbeDurationDesc,
+		prometheus.GaugeValue,
+		inspect.HealthLastProbe.Duration.Seconds(),
+		hostname,
+		containerID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		containerHealthLastProbeExitCodeDesc,
+		prometheus.GaugeValue,
+		float64(inspect.HealthLastProbe.ExitCode),
+		hostname,
+		containerID,
+	)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/h3rmt/docker-exporter/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func formatContainerRestartsInWindow(ch chan<- prometheus.Metric, hostname string, containerID string, restarts docker.RestartSummary) {
+	for window, count := range restarts.InWindow {
+		ch <- prometheus.MustNewConstMetric(
+			containerRestartsInWindowDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			hostname,
+			containerID,
+			formatWindow(window),
+		)
+	}
+}
+
+func formatContainerCrashlooping(ch chan<- prometheus.Metric, hostname string, containerID string, restarts docker.RestartSummary) {
+	ch <- prometheus.MustNewConstMetric(
+		containerCrashloopingDesc,
+		prometheus.GaugeValue,
+		boolToFloat(restarts.Crashlooping),
+		hostname,
+		containerID,
+	)
+}
+
+// formatWindow formats a duration without zero units (5m0s -> 5m, 1h0m0s -> 1h)
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -86,84 +86,120 @@ func formatContainerCpuPercent(ch chan<- prometheus.Metric, hostname string, con
 	)
 }
 
-func formatContainerMemLimitKiB(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+func formatContainerCpuThrottling(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerMemLimitKiBDesc,
-		prometheus.GaugeValue,
-		float64(stat.MemoryLimitKiB),
+		containerCpuPeriodsDesc,
+		prometheus.CounterValue,
+		float64(stat.Cpu.Periods),
 		hostname,
 		containerID,
 	)
-}
-
-func formatContainerMemUsageKiB(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerMemUsageKiBDesc,
-		prometheus.GaugeValue,
-		float64(stat.MemoryUsageKiB),
+		containerCpuThrottledPeriodsDesc,
+		prometheus.CounterValue,
+		float64(stat.Cpu.ThrottledPeriods),
 		hostname,
 		containerID,
 	)
-}
-
-func formatContainerNetSendBytes(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerNetSendBytesDesc,
+		containerCpuThrottledSecondsDesc,
 		prometheus.CounterValue,
-		float64(stat.Net.SendBytes),
+		float64(stat.Cpu.ThrottledTimeNS)/1000000000.0,
 		hostname,
 		containerID,
 	)
 }
 
-func formatContainerNetSendDropped(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+func formatContainerMemLimitKiB(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerNetSendDroppedDesc,
-		prometheus.CounterValue,
-		float64(stat.Net.SendDropped),
+		containerMemLimitKiBDesc,
+		prometheus.GaugeValue,
+		float64(stat.Memory.LimitBytes/1024),
 		hostname,
 		containerID,
 	)
 }
 
-func formatContainerNetSendErrors(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+func formatContainerMemUsageKiB(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerNetSendErrorsDesc,
-		prometheus.CounterValue,
-		float64(stat.Net.SendErrors),
+		containerMemUsageKiBDesc,
+		prometheus.GaugeValue,
+		float64(stat.Memory.UsageBytes/1024),
 		hostname,
 		containerID,
 	)
 }
 
-func formatContainerNetRecvBytes(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+func formatContainerMemLimit(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerNetRecvBytesDesc,
-		prometheus.CounterValue,
-		float64(stat.Net.RecvBytes),
+		containerMemLimitDesc,
+		prometheus.GaugeValue,
+		float64(stat.Memory.LimitBytes),
 		hostname,
 		containerID,
 	)
 }
 
-func formatContainerNetRecvDropped(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+func formatContainerMemUsage(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
 	ch <- prometheus.MustNewConstMetric(
-		containerNetRecvDroppedDesc,
-		prometheus.CounterValue,
-		float64(stat.Net.RecvDropped),
+		containerMemUsageDesc,
+		prometheus.GaugeValue,
+		float64(stat.Memory.UsageBytes),
 		hostname,
 		containerID,
 	)
 }
 
-func formatContainerNetRecvErrors(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
-	ch <- prometheus.MustNewConstMetric(
-		containerNetRecvErrorsDesc,
-		prometheus.CounterValue,
-		float64(stat.Net.RecvErrors),
-		hostname,
-		containerID,
-	)
+func formatContainerMemStats(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+	for key, value := range stat.Memory.Stats {
+		metric, ok := containerMemStatDescs[key]
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			metric.desc,
+			metric.valueType,
+			float64(value),
+			hostname,
+			containerID,
+		)
+	}
+}
+
+func formatContainerNet(ch chan<- prometheus.Metric, descs containerNetDescs, hostname string, containerID string, net docker.ContainerNetStats, extraLabels ...string) {
+	labels := append([]string{hostname, containerID}, extraLabels...)
+	for _, metric := range []struct {
+		desc  *prometheus.Desc
+		value uint64
+	}{
+		{descs.sendBytes, net.SendBytes},
+		{descs.sendPackets, net.SendPackets},
+		{descs.sendDropped, net.SendDropped},
+		{descs.sendErrors, net.SendErrors},
+		{descs.recvBytes, net.RecvBytes},
+		{descs.recvPackets, net.RecvPackets},
+		{descs.recvDropped, net.RecvDropped},
+		{descs.recvErrors, net.RecvErrors},
+	} {
+		ch <- prometheus.MustNewConstMetric(
+			metric.desc,
+			prometheus.CounterValue,
+			float64(metric.value),
+			labels...,
+		)
+	}
+}
+
+func formatContainerNetInterfaces(ch chan<- prometheus.Metric, descs containerNetDescs, hostname string, containerID string, stat docker.ContainerStats, inspect docker.ContainerInspect) {
+	for iface, net := range stat.Interfaces {
+		// interfaces are matched to docker networks by MAC address (cgroupfs backend),
+		// without it only if there is exactly one of both
+		network := inspect.NetworkMacs[net.MacAddress]
+		if network == "" && len(stat.Interfaces) == 1 && len(inspect.Networks) == 1 {
+			network = inspect.Networks[0]
+		}
+		formatContainerNet(ch, descs, hostname, containerID, net, iface, network)
+	}
 }
 
 func formatBlockInputBytes(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
@@ -185,3 +221,28 @@ func formatBlockOutputBytes(ch chan<- prometheus.Metric, hostname string, contai
 		containerID,
 	)
 }
+
+func formatContainerBlkio(ch chan<- prometheus.Metric, hostname string, containerID string, stat docker.ContainerStats) {
+	for _, device := range stat.Blkio {
+		majorMinor := device.MajorMinor()
+		for _, metric := range []struct {
+			desc  *prometheus.Desc
+			value uint64
+		}{
+			{containerBlkioReadBytesDesc, device.ReadBytes},
+			{containerBlkioWriteBytesDesc, device.WriteBytes},
+			{containerBlkioReadsDesc, device.ReadOps},
+			{containerBlkioWritesDesc, device.WriteOps},
+		} {
+			ch <- prometheus.MustNewConstMetric(
+				metric.desc,
+				prometheus.CounterValue,
+				float64(metric.value),
+				hostname,
+				containerID,
+				device.Name,
+				majorMinor,
+			)
+		}
+	}
+}
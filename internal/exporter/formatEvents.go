@@ -0,0 +1,30 @@
+package exporter
+
+import (
+	"github.com/h3rmt/docker-exporter/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func formatContainerEvents(ch chan<- prometheus.Metric, hostname string, counts docker.EventCounts) {
+	for action, count := range counts.Container {
+		ch <- prometheus.MustNewConstMetric(
+			containerEventsDesc,
+			prometheus.CounterValue,
+			float64(count),
+			hostname,
+			action,
+		)
+	}
+}
+
+func formatImageEvents(ch chan<- prometheus.Metric, hostname string, counts docker.EventCounts) {
+	for action, count := range counts.Image {
+		ch <- prometheus.MustNewConstMetric(
+			imageEventsDesc,
+			prometheus.CounterValue,
+			float64(count),
+			hostname,
+			action,
+		)
+	}
+}
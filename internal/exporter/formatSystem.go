@@ -1,6 +1,8 @@
 package exporter
 
 import (
+	"time"
+
 	"github.com/h3rmt/docker-exporter/internal/docker"
 	"github.com/h3rmt/docker-exporter/internal/osinfo"
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,6 +18,79 @@ func formatSystemInfo(ch chan<- prometheus.Metric, hostname string, version stri
 	)
 }
 
+func formatCollectorDuration(ch chan<- prometheus.Metric, hostname string, collector string, duration time.Duration, success bool) {
+	ch <- prometheus.MustNewConstMetric(
+		collectorDurationDesc,
+		prometheus.GaugeValue,
+		duration.Seconds(),
+		hostname,
+		collector,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		collectorSuccessDesc,
+		prometheus.GaugeValue,
+		boolToFloat(success),
+		hostname,
+		collector,
+	)
+}
+
+func formatDockerAPIDurations(ch chan<- prometheus.Metric, hostname string, latencies map[string]docker.LatencyHistogram) {
+	for op, histogram := range latencies {
+		ch <- prometheus.MustNewConstHistogram(
+			dockerAPIDurationDesc,
+			histogram.Count,
+			histogram.Sum,
+			histogram.Buckets,
+			hostname,
+			op,
+		)
+	}
+}
+
+func formatCacheAges(ch chan<- prometheus.Metric, hostname string, ages map[string]time.Duration) {
+	for cache, age := range ages {
+		ch <- prometheus.MustNewConstMetric(
+			cacheAgeDesc,
+			prometheus.GaugeValue,
+			age.Seconds(),
+			hostname,
+			cache,
+		)
+	}
+}
+
+func formatScrapePartial(ch chan<- prometheus.Metric, hostname string, partial bool) {
+	ch <- prometheus.MustNewConstMetric(
+		scrapePartialDesc,
+		prometheus.GaugeValue,
+		boolToFloat(partial),
+		hostname,
+	)
+}
+
+func formatSystemTrackedContainers(ch chan<- prometheus.Metric, hostname string, tracked int) {
+	ch <- prometheus.MustNewConstMetric(
+		trackedContainersDesc,
+		prometheus.GaugeValue,
+		float64(tracked),
+		hostname,
+	)
+}
+
+func formatSystemRequestErrors(ch chan<- prometheus.Metric, hostname string, errors map[docker.RequestError]uint64) {
+	for key, count := range errors {
+		ch <- prometheus.MustNewConstMetric(
+			dockerRequestErrorsDesc,
+			prometheus.CounterValue,
+			float64(count),
+			hostname,
+			key.Op,
+			key.Reason,
+		)
+	}
+}
+
 func formatSystemHostInfo(ch chan<- prometheus.Metric, hostname string, info osinfo.OSInfo) {
 	ch <- prometheus.MustNewConstMetric(
 		hostOSInfoDesc,
@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/h3rmt/docker-exporter/internal/docker"
+	"github.com/h3rmt/docker-exporter/internal/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HandlerConfig holds the settings of the /metrics handler
+type HandlerConfig struct {
+	// subtracted from the scrape timeout sent by prometheus to leave time for sending the response
+	TimeoutOffset time.Duration
+	// additional metrics like the go runtime metrics, nil for none
+	Internal          prometheus.Gatherer
+	EnableOpenMetrics bool
+}
+
+// Handler is the /metrics handler, it collects with a new registry per request,
+// so the collector can use the request context and the scrape timeout.
+//
+// The collect[] query parameter selects the collector groups to run, all enabled groups are run if it is not set.
+type Handler struct {
+	client        *docker.Client
+	version       string
+	handlerConfig HandlerConfig
+
+	mu        sync.RWMutex
+	config    CollectorConfig
+	collector *DockerCollector
+}
+
+func NewHandler(client *docker.Client, version string, config CollectorConfig, handlerConfig HandlerConfig) *Handler {
+	h := &Handler{client: client, version: version, handlerConfig: handlerConfig}
+	h.SetCollectorConfig(config)
+	return h
+}
+
+// SetCollectorConfig changes the enabled collectors, scrapes in flight finish with the old config
+func (h *Handler) SetCollectorConfig(config CollectorConfig) {
+	collector := NewDockerCollector(h.client, h.version, config)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config = config
+	h.collector = collector
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	h.mu.RLock()
+	config, collector := h.config, h.collector
+	h.mu.RUnlock()
+
+	if groups := r.URL.Query()["collect[]"]; len(groups) > 0 {
+		filtered, err := config.Filter(groups)
+		if err != nil {
+			log.GetLogger().WarnContext(ctx, "Invalid collect[] parameter", "error", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.GetLogger().Log(ctx, log.LevelTrace, "Filtering collectors", "collectors", groups)
+		collector = NewDockerCollector(h.client, h.version, filtered)
+	}
+	if timeout := scrapeTimeout(r, h.handlerConfig.TimeoutOffset); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		log.GetLogger().Log(ctx, log.LevelTrace, "Using scrape timeout", "timeout", timeout)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector.WithContext(ctx))
+	var gatherer prometheus.Gatherer = registry
+	if h.handlerConfig.Internal != nil {
+		gatherer = prometheus.Gatherers{h.handlerConfig.Internal, registry}
+	}
+	promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: h.handlerConfig.EnableOpenMetrics,
+	}).ServeHTTP(w, r)
+}
+
+// scrapeTimeout reads the X-Prometheus-Scrape-Timeout-Seconds header, returns 0 if it is not set
+func scrapeTimeout(r *http.Request, offset time.Duration) time.Duration {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		log.GetLogger().WarnContext(r.Context(), "Invalid scrape timeout header", "value", header)
+		return 0
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - offset
+	if timeout <= 0 {
+		// keep some time to collect anything at all
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return timeout
+}
@@ -0,0 +1,47 @@
+package exporter
+
+import (
+	"strings"
+)
+
+const containerLabelPrefix = "container_label_"
+
+// containerLabelNames converts docker labels into prometheus label names
+//
+// com.docker.compose.project -> container_label_com_docker_compose_project
+func containerLabelNames(labels []string) []string {
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = containerLabelPrefix + sanitizeLabelName(label)
+	}
+	return names
+}
+
+// sanitizeLabelName replaces all characters not allowed in prometheus label names with '_'
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// uniqueContainerLabels removes empty labels and labels that map to the same prometheus label name
+func uniqueContainerLabels(labels []string) []string {
+	seen := make(map[string]bool, len(labels))
+	unique := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		name := sanitizeLabelName(label)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, label)
+	}
+	return unique
+}
@@ -11,6 +11,9 @@ const (
 
 var logger *slog.Logger
 
+// level of the logger, can be changed at runtime with SetLevel
+var level = new(slog.LevelVar)
+
 func init() {
 	// Default to text format (logfmt-like) with INFO level
 	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
@@ -22,18 +25,7 @@ func init() {
 // InitLogger initializes the logger with the specified format and verbosity
 func InitLogger(format string, verbose bool, trace bool, quiet bool) {
 	var handler slog.Handler
-	var level slog.Level
-
-	// Determine log level
-	if trace {
-		level = LevelTrace
-	} else if verbose {
-		level = slog.LevelDebug
-	} else if quiet {
-		level = slog.LevelWarn
-	} else {
-		level = slog.LevelInfo
-	}
+	SetLevel(verbose, trace, quiet)
 
 	// Create handler based on format
 	opts := &slog.HandlerOptions{
@@ -63,6 +55,19 @@ func InitLogger(format string, verbose bool, trace bool, quiet bool) {
 	logger = slog.New(handler)
 }
 
+// SetLevel changes the level of the logger initialized with InitLogger
+func SetLevel(verbose bool, trace bool, quiet bool) {
+	if trace {
+		level.Set(LevelTrace)
+	} else if verbose {
+		level.Set(slog.LevelDebug)
+	} else if quiet {
+		level.Set(slog.LevelWarn)
+	} else {
+		level.Set(slog.LevelInfo)
+	}
+}
+
 // GetLogger returns the underlying slog.Logger instance
 // Use with slog.NewLogLogger() to get a *log.Logger for stdlib compatibility
 func GetLogger() *slog.Logger {
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/h3rmt/docker-exporter/internal/docker"
@@ -84,6 +83,11 @@ type containerItem struct {
 	MaxCpus         float64  `json:"max_cpus"`
 	CpuLimitedUsage uint64   `json:"cpu_limited_usage"`
 	MaxLimitedCpus  float64  `json:"max_limited_cpus"`
+	Health          string   `json:"health"`
+	FailingStreak   int      `json:"health_failing_streak"`
+	Restarts5m      int      `json:"restarts_5m"`
+	Restarts1h      int      `json:"restarts_1h"`
+	Crashlooping    bool     `json:"crashlooping"`
 }
 
 func HandleAPIContainers(c *docker.Client) http.HandlerFunc {
@@ -97,85 +101,140 @@ func HandleAPIContainers(c *docker.Client) http.HandlerFunc {
 			return
 		}
 
-		var items []containerItem
-		containers, err := c.ListAllRunningContainers(ctx)
+		snapshot, err := c.ContainerSnapshot(ctx, docker.SnapshotOptions{Stats: true, Cpu: true})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		log.GetLogger().Log(ctx, log.LevelTrace, "found containers", "containers", len(containers))
-		var wg sync.WaitGroup
-		var mu sync.Mutex
-		items = make([]containerItem, 0, len(containers))
-
-		for _, cnt := range containers {
-			wg.Add(1)
-			go func(ci docker.ContainerInfo) {
-				defer wg.Done()
-
-				// inspect for exit code
-				var exitCode int
-				var restartCount int
-				var nanoCpus int64
-				if insp, err := c.InspectContainer(ctx, ci.ID, false); err == nil {
-					exitCode = insp.ExitCode
-					restartCount = insp.RestartCount
-					nanoCpus = insp.NanoCpus
-				}
+		log.GetLogger().Log(ctx, log.LevelTrace, "found containers", "containers", len(snapshot.Containers))
+		items := make([]containerItem, 0, len(snapshot.Containers))
+
+		for _, ci := range snapshot.Containers {
+			result := snapshot.Results[ci.ID]
+
+			// inspect for exit code
+			var exitCode int
+			var restartCount int
+			var nanoCpus int64
+			health := container.NoHealthcheck
+			var failingStreak int
+			if result.InspectErr == nil {
+				insp := result.Inspect
+				exitCode = insp.ExitCode
+				restartCount = insp.RestartCount
+				nanoCpus = insp.NanoCpus
+				health = insp.HealthStatus
+				failingStreak = insp.HealthFailingStreak
+			}
 
-				// stats might fail for exited containers; ignore errors per item
-				var memKiB uint64
-				var memLimitKiB uint64
-				var cpuPercentOfSystem uint64
-				var maxCPUs float64
-				var maxLimitedCpus float64
-				var cpuLimitedUsage uint64
-				if ci.State == container.StateRunning {
-					if st, err := c.GetContainerStats(ctx, ci.ID, true); err == nil {
-						memKiB = st.MemoryUsageKiB
-						memLimitKiB = st.MemoryLimitKiB
-
-						// Compute CPU% of system (docker stats style)
-						cpuDelta := st.Cpu.UsageNS - st.Cpu.PreUsageNS
-						sysDelta := st.Cpu.SystemUsageNS - st.Cpu.PreSystemUsageNS
-						maxCPUs = float64(st.Cpu.OnlineCpus)
-						if nanoCpus > 0 {
-							maxLimitedCpus = float64(nanoCpus) / 1000000000.0
-						} else {
-							maxLimitedCpus = maxCPUs
-						}
-						if sysDelta > 0 {
-							cpuPercentOfSystem = uint64(float64(cpuDelta) / float64(sysDelta) * 100.0)
-						}
-						cpuLimitedUsage = uint64((float64(cpuPercentOfSystem) / maxLimitedCpus) * maxCPUs)
-					}
+			// stats might fail for exited containers; ignore errors per item
+			var memKiB uint64
+			var memLimitKiB uint64
+			var cpuPercentOfSystem uint64
+			var maxCPUs float64
+			var maxLimitedCpus float64
+			var cpuLimitedUsage uint64
+			if ci.State == container.StateRunning && result.StatsErr == nil {
+				st := result.Stats
+				memKiB = st.Memory.UsageBytes / 1024
+				memLimitKiB = st.Memory.LimitBytes / 1024
+
+				// Compute CPU% of system (docker stats style)
+				cpuDelta := st.Cpu.UsageNS - st.Cpu.PreUsageNS
+				sysDelta := st.Cpu.SystemUsageNS - st.Cpu.PreSystemUsageNS
+				maxCPUs = float64(st.Cpu.OnlineCpus)
+				if nanoCpus > 0 {
+					maxLimitedCpus = float64(nanoCpus) / 1000000000.0
+				} else {
+					maxLimitedCpus = maxCPUs
 				}
-				stateStr := string(ci.State)
-				item := containerItem{
-					ID:              ci.ID,
-					ImageId:         ci.ImageID,
-					Names:           ci.Names,
-					Created:         ci.Created,
-					State:           stateStr,
-					Exited:          strings.ToLower(stateStr) == "exited",
-					ExitCode:        exitCode,
-					RestartCount:    restartCount,
-					MemUsageKiB:     memKiB,
-					MemLimitKiB:     memLimitKiB,
-					CpuUsage:        cpuPercentOfSystem,
-					MaxCpus:         maxCPUs,
-					CpuLimitedUsage: cpuLimitedUsage,
-					MaxLimitedCpus:  maxLimitedCpus,
+				if sysDelta > 0 {
+					cpuPercentOfSystem = uint64(float64(cpuDelta) / float64(sysDelta) * 100.0)
 				}
+				cpuLimitedUsage = uint64((float64(cpuPercentOfSystem) / maxLimitedCpus) * maxCPUs)
+			}
+			restarts := c.ContainerRestarts(ci.ID)
+			stateStr := string(ci.State)
+			item := containerItem{
+				ID:              ci.ID,
+				ImageId:         ci.ImageID,
+				Names:           ci.Names,
+				Created:         ci.Created,
+				State:           stateStr,
+				Exited:          strings.ToLower(stateStr) == "exited",
+				ExitCode:        exitCode,
+				RestartCount:    restartCount,
+				MemUsageKiB:     memKiB,
+				MemLimitKiB:     memLimitKiB,
+				CpuUsage:        cpuPercentOfSystem,
+				MaxCpus:         maxCPUs,
+				CpuLimitedUsage: cpuLimitedUsage,
+				MaxLimitedCpus:  maxLimitedCpus,
+				Health:          string(health),
+				FailingStreak:   failingStreak,
+				Restarts5m:      restarts.InWindow[5*time.Minute],
+				Restarts1h:      restarts.InWindow[time.Hour],
+				Crashlooping:    restarts.Crashlooping,
+			}
+
+			items = append(items, item)
+		}
+		writeJSON(w, items)
+	}
+}
+
+type projectItem struct {
+	Name           string         `json:"name"`
+	Containers     map[string]int `json:"containers"`
+	CpuPercentHost float64        `json:"cpu_percent_host"`
+	MemUsageKiB    uint64         `json:"mem_usage_kib"`
+	NetSendBytes   uint64         `json:"net_send_bytes"`
+	NetRecvBytes   uint64         `json:"net_recv_bytes"`
+}
+
+func HandleAPIProjects(c *docker.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log.GetLogger().Log(ctx, log.LevelTrace, "handle api projects")
+
+		// Return empty list if not ready yet
+		if !glob.IsReady() {
+			writeJSON(w, []projectItem{})
+			return
+		}
+
+		snapshot, err := c.ContainerSnapshot(ctx, docker.SnapshotOptions{Stats: true, Cpu: true})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		stats := make(map[string]docker.ContainerStats, len(snapshot.Containers))
+		for _, cnt := range snapshot.Containers {
+			// only running containers of a compose project contribute to the usage totals
+			if cnt.ComposeProject == "" || cnt.State != container.StateRunning {
+				continue
+			}
+			if result := snapshot.Results[cnt.ID]; result.StatsErr == nil {
+				stats[cnt.ID] = result.Stats
+			}
+		}
 
-				mu.Lock()
-				items = append(items, item)
-				mu.Unlock()
-			}(cnt)
+		projects := docker.SummarizeProjects(snapshot.Containers, stats)
+		items := make([]projectItem, len(projects))
+		for idx, project := range projects {
+			counts := make(map[string]int, len(project.Containers))
+			for state, count := range project.Containers {
+				counts[string(state)] = count
+			}
+			items[idx] = projectItem{
+				Name:           project.Name,
+				Containers:     counts,
+				CpuPercentHost: project.CpuPercentHost,
+				MemUsageKiB:    project.MemoryUsageBytes / 1024,
+				NetSendBytes:   project.NetSendBytes,
+				NetRecvBytes:   project.NetRecvBytes,
+			}
 		}
-		log.GetLogger().Log(ctx, log.LevelTrace, "waiting for container stats", "containers", len(containers))
-		wg.Wait()
-		log.GetLogger().Log(ctx, log.LevelTrace, "done waiting for container stats")
 		writeJSON(w, items)
 	}
 }
@@ -8,6 +8,9 @@ let cpuDataUser = JSON.parse(document.getElementById('CPUDataUser').textContent)
 let cpuDataSystem = JSON.parse(document.getElementById('CPUDataSystem').textContent);
 /** @type {number[]} */
 let memData = JSON.parse(document.getElementById('MemData').textContent);
+/** path the exporter is served under behind a reverse proxy, '' if served at the root
+ * @type {string} */
+const basePath = JSON.parse(document.getElementById('BasePath').textContent);
 
 console.log(labels);
 console.log(cpuData);
@@ -16,7 +19,7 @@ console.log(cpuDataSystem);
 console.log(memData);
 
 async function fetchJSON(url, allowErrors = false) {
-    const r = await fetch(url, {cache: 'no-store'});
+    const r = await fetch(basePath + url, {cache: 'no-store'});
     if (!allowErrors && !r.ok) throw new Error('HTTP ' + r.status);
     return r.json();
 }
@@ -270,7 +273,9 @@ async function loadContainers() {
          *   mem_limit_kib: number, state: string,
          *   exit_code: number, restart_count: number,
          *   cpu_usage: number, max_cpus: number,
-         *   max_limited_cpus: number, cpu_limited_usage: number
+         *   max_limited_cpus: number, cpu_limited_usage: number,
+         *   health: string, health_failing_streak: number,
+         *   restarts_5m: number, restarts_1h: number, crashlooping: boolean
          * }[] }*/
         let container = await fetchJSON('/api/containers');
         container.sort((a, b) => {
@@ -291,6 +296,9 @@ async function loadContainers() {
         for (const c of container) {
             const tr = document.createElement('tr');
             const stateClass = c.exited ? 'exited' : 'running';
+            if (c.crashlooping) {
+                tr.classList.add('crashlooping');
+            }
 
             // Name column
             const tdName = document.createElement('td');
@@ -385,7 +393,26 @@ async function loadContainers() {
             const statusSpan = document.createElement('span');
             statusSpan.className = 'status ' + stateClass;
             statusSpan.innerText = c.state + (c.exited ? (' (exit=' + c.exit_code + ')') : '') + (c.restart_count ? ' (' + c.restart_count + ')' : '');
+            if (c.restart_count) {
+                statusSpan.classList.add('underline');
+                statusSpan.setAttribute("data-tippy-content",
+                    (c.crashlooping ? "<b>Crash-looping</b><br>" : "") +
+                    "<b>Restarts (5m): </b>" + c.restarts_5m + "<br>" +
+                    "<b>Restarts (1h): </b>" + c.restarts_1h
+                )
+            }
             tdStatus.appendChild(statusSpan);
+            if (c.health && c.health !== 'none') {
+                const healthSpan = document.createElement('span');
+                healthSpan.className = 'status status-' + c.health;
+                healthSpan.style.marginLeft = '4px';
+                healthSpan.innerText = c.health;
+                if (c.health_failing_streak) {
+                    healthSpan.classList.add('underline');
+                    healthSpan.setAttribute("data-tippy-content", "<b>Failing streak: </b>" + c.health_failing_streak);
+                }
+                tdStatus.appendChild(healthSpan);
+            }
             tr.appendChild(tdStatus);
 
             tbody.appendChild(tr);
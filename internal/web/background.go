@@ -10,8 +10,6 @@ import (
 const Delay = 20 * time.Second
 const DataPoints = 30
 
-var ticker *time.Ticker
-
 var data []DataPoint
 
 // fill with dummy data
@@ -28,16 +26,22 @@ type DataPoint struct {
 	Data UsageResponse
 }
 
-func CollectInBg() {
-	ctx := context.Background()
-	if ticker != nil {
-		ticker.Stop()
-	}
-	ticker = time.NewTicker(Delay)
+// CollectInBg collects the homepage chart data every Delay until ctx is cancelled
+func CollectInBg(ctx context.Context) {
+	ticker := time.NewTicker(Delay)
+	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
 		// calculate cpu usage over long period to be more accurate
 		usage, usageUser, usageSystem, err := readCPUInfo(ctx, Delay/2)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			log.GetLogger().ErrorContext(ctx, "failed to read cpu", "error", err)
 		}
@@ -53,13 +57,6 @@ func CollectInBg() {
 	}
 }
 
-func StopCollect() {
-	if ticker == nil {
-		return
-	}
-	ticker.Stop()
-}
-
 func GetData() []DataPoint {
 	return data
 }
@@ -35,7 +35,11 @@ func readCPUInfo(ctx context.Context, measureDuration time.Duration) (float64, f
 	if err != nil {
 		return 0, 0, 0, err
 	}
-	time.Sleep(measureDuration)
+	select {
+	case <-time.After(measureDuration):
+	case <-ctx.Done():
+		return 0, 0, 0, ctx.Err()
+	}
 	user1, system1, idle1, total1, _, err := readProcStat(ctx)
 	if err != nil {
 		return 0, 0, 0, err
@@ -74,7 +74,8 @@ func HandleAsset() http.HandlerFunc {
 //go:embed assets/main.html
 var index string
 
-func HandleRoot() http.HandlerFunc {
+// HandleRoot serves the homepage, basePath is prepended to all asset and api urls ("" if served at the root)
+func HandleRoot(basePath string) http.HandlerFunc {
 	funcMap := template.FuncMap{
 		"toJson": func(v any) string {
 			b, err := json.Marshal(v)
@@ -108,6 +109,7 @@ func HandleRoot() http.HandlerFunc {
 			MemData       []float64
 			TotalMem      uint64
 			CpuCount      uint64
+			BasePath      string
 		}
 
 		cd := chartData{
@@ -118,6 +120,7 @@ func HandleRoot() http.HandlerFunc {
 			MemData:       make([]float64, 0),
 			TotalMem:      totalMem / 1024, // turn into KiB
 			CpuCount:      cpuCount,
+			BasePath:      basePath,
 		}
 
 		for i := range dataPoints {